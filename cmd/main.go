@@ -6,9 +6,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 )
 
 var addr = flag.String("addr", ":8080", "http service address")
+var blockedWords = flag.String("blocked-words", "", "comma-separated words to mask in chat")
+var maxRTTSpread = flag.Duration("max-rtt-spread", 0, "most the round trip times of matched players may differ (default 60ms)")
 
 func main() {
 	// Set up logging
@@ -22,8 +25,14 @@ func main() {
 
 	// Start server
 	flag.Parse()
-	pm := internal.NewPartyManager()
-	go pm.Run()
+	var opts []internal.PartyManagerOption
+	if *blockedWords != "" {
+		opts = append(opts, internal.WithChatFilter(internal.NewWordFilter(strings.Split(*blockedWords, ",")...)))
+	}
+	if *maxRTTSpread > 0 {
+		opts = append(opts, internal.WithMaxRTTSpread(*maxRTTSpread))
+	}
+	pm := internal.NewPartyManager(opts...)
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		internal.ServeWs(pm, w, r)
 	})
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Client timestamps may lag the stimulus by this much before they are
+	// treated as forged, to allow for error in the clock estimate.
+	clockSkewTolerance = 50 * time.Millisecond
+
+	// No human sends more than maxInputsPerBurst inputs within burstWindow.
+	burstWindow       = time.Second
+	maxInputsPerBurst = 15
+)
+
+// CheatReason names the check a rejected input failed.
+type CheatReason string
+
+const (
+	CheatReasonInhumanReaction CheatReason = "inhumanReaction"
+	CheatReasonBeforeStimulus  CheatReason = "beforeStimulus"
+	CheatReasonBurst           CheatReason = "burst"
+	CheatReasonUnseenPuzzle    CheatReason = "unseenPuzzle"
+)
+
+// CheatEvent describes a rejected input. It is logged as JSON so it can be
+// picked out of the server logs.
+type CheatEvent struct {
+	GameID       GameID      `json:"gameId"`
+	PlayerID     ClientID    `json:"playerId"`
+	Reason       CheatReason `json:"reason"`
+	Round        int         `json:"round,omitempty"`
+	Detail       string      `json:"detail"`
+	Disqualified bool        `json:"disqualified"`
+	Time         time.Time   `json:"time"`
+}
+
+// shownStimulus records when the stimulus or puzzle of a round was sent.
+type shownStimulus struct {
+	round int
+	kind  RoundType
+	at    time.Time
+}
+
+// antiCheat rejects inputs no human could have made. It relies on the
+// GameMode reporting every stimulus it shows through Game.markStimulus.
+//
+// It is owned by the Game goroutine.
+type antiCheat struct {
+	minReaction time.Duration
+	stimuli     map[int]shownStimulus
+	latest      *shownStimulus
+	inputs      map[ClientID][]time.Time
+	flags       map[ClientID][]CheatReason
+}
+
+// newAntiCheat creates an antiCheat for the given settings. A zero
+// MinReactionMs disables the reaction time check.
+func newAntiCheat(settings GameSettings) *antiCheat {
+	return &antiCheat{
+		minReaction: time.Duration(settings.MinReactionMs) * time.Millisecond,
+		stimuli:     make(map[int]shownStimulus),
+		inputs:      make(map[ClientID][]time.Time),
+		flags:       make(map[ClientID][]CheatReason),
+	}
+}
+
+// markStimulus records a stimulus or puzzle sent to the players.
+func (ac *antiCheat) markStimulus(round int, kind RoundType, at time.Time) {
+	s := shownStimulus{round: round, kind: kind, at: at}
+	ac.stimuli[round] = s
+	ac.latest = &s
+}
+
+// check validates an input whose time was already corrected for latency.
+// est is the player's clock estimate, if known. It returns the reason and
+// a description if the input must be rejected.
+func (ac *antiCheat) check(in PlayerInput, est ClockEstimate, hasEst bool) (CheatReason, string, bool) {
+	if ac.isBurst(in.ClientID, in.ReceivedAt) {
+		return CheatReasonBurst, fmt.Sprintf("more than %d inputs within %v", maxInputsPerBurst, burstWindow), true
+	}
+
+	var stimulus shownStimulus
+	switch in.Kind {
+	case ClientMessageSubmitAnswer:
+		s, ok := ac.stimuli[in.Round]
+		if !ok || s.kind != RoundTypePattern {
+			return CheatReasonUnseenPuzzle, fmt.Sprintf("answer to round %d which showed no puzzle", in.Round), true
+		}
+		stimulus = s
+	default:
+		// Presses before the stimulus are false starts, not cheats
+		if ac.latest == nil || ac.latest.kind != RoundTypeReaction || in.ReceivedAt.Before(ac.latest.at) {
+			return "", "", false
+		}
+		stimulus = *ac.latest
+	}
+
+	if in.ClientTime > 0 && hasEst {
+		pressedAt := time.UnixMilli(in.ClientTime).Add(-est.Offset)
+		if pressedAt.Before(stimulus.at.Add(-clockSkewTolerance)) {
+			early := stimulus.at.Sub(pressedAt).Milliseconds()
+			return CheatReasonBeforeStimulus, fmt.Sprintf("timestamped %dms before round %d's stimulus", early, stimulus.round), true
+		}
+	}
+
+	if reaction := in.At.Sub(stimulus.at); ac.minReaction > 0 && reaction < ac.minReaction {
+		return CheatReasonInhumanReaction, fmt.Sprintf("reacted in %dms to round %d", reaction.Milliseconds(), stimulus.round), true
+	}
+	return "", "", false
+}
+
+// isBurst records an input and reports whether the player exceeded the
+// input rate any human could reach.
+func (ac *antiCheat) isBurst(cid ClientID, at time.Time) bool {
+	recent := ac.inputs[cid][:0]
+	for _, t := range ac.inputs[cid] {
+		if at.Sub(t) < burstWindow {
+			recent = append(recent, t)
+		}
+	}
+	ac.inputs[cid] = append(recent, at)
+	return len(ac.inputs[cid]) > maxInputsPerBurst
+}
+
+// flag records that a player failed a check. Each reason is kept once.
+func (ac *antiCheat) flag(cid ClientID, reason CheatReason) {
+	for _, r := range ac.flags[cid] {
+		if r == reason {
+			return
+		}
+	}
+	ac.flags[cid] = append(ac.flags[cid], reason)
+}
+
+// annotate adds the recorded flags to a final ranking.
+func (ac *antiCheat) annotate(ranking []PlayerStanding) {
+	for i := range ranking {
+		ranking[i].Flags = ac.flags[ranking[i].PlayerID]
+	}
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+)
+
+// Page sizes of the party browser.
+const (
+	defaultListingLimit = 20
+	maxListingLimit     = 50
+)
+
+// partyBrowser is a client subscribed to listing updates.
+type partyBrowser struct {
+	client *Client
+	filter PartyListingFilter
+}
+
+// PartyListing describes a listed Party in the party browser.
+type PartyListing struct {
+	PartyID  PartyID `json:"partyId"`
+	HostName string  `json:"hostName"`
+	Members  int     `json:"members"`
+	MaxSize  int     `json:"maxSize"`
+	Mode     string  `json:"mode"`
+	InGame   bool    `json:"inGame"`
+
+	PasswordProtected bool `json:"passwordProtected"`
+}
+
+// PartyListingFilter selects and pages the listings a browser is shown.
+// Zero values match every party.
+type PartyListingFilter struct {
+	Mode       string `json:"mode,omitempty"`
+	NotFull    bool   `json:"notFull,omitempty"`
+	NotInGame  bool   `json:"notInGame,omitempty"`
+	NoPassword bool   `json:"noPassword,omitempty"`
+	Offset     int    `json:"offset,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// matches reports whether a listing passes the filter.
+func (f PartyListingFilter) matches(l PartyListing) bool {
+	switch {
+	case f.Mode != "" && !strings.EqualFold(f.Mode, l.Mode):
+		return false
+	case f.NotFull && l.Members >= l.MaxSize:
+		return false
+	case f.NotInGame && l.InGame:
+		return false
+	case f.NoPassword && l.PasswordProtected:
+		return false
+	}
+	return true
+}
+
+// page filters the listings and returns the requested page, along with
+// the number of listings that matched.
+func (f PartyListingFilter) page(listings []PartyListing) ([]PartyListing, int) {
+	matched := make([]PartyListing, 0, len(listings))
+	for _, l := range listings {
+		if f.matches(l) {
+			matched = append(matched, l)
+		}
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultListingLimit
+	}
+	limit = min(limit, maxListingLimit)
+	start := min(max(f.Offset, 0), len(matched))
+	end := min(start+limit, len(matched))
+	return matched[start:end], len(matched)
+}
+
+// listing describes the Party for the party browser.
+func (p *Party) listing() PartyListing {
+	l := PartyListing{
+		PartyID: p.ID,
+		Members: len(p.Members),
+		MaxSize: p.MaxSize,
+		Mode:    p.settings.Mode,
+		InGame:  p.game != nil,
+
+		PasswordProtected: p.password != nil,
+	}
+	if host, ok := p.Members[p.HostID]; ok {
+		l.HostName = host.Client.Profile().DisplayName
+	}
+	return l
+}
+
+// partyListings returns the listings of all listed parties, fullest first.
+// Locked parties cannot be joined, so they are left out.
+func (pm *PartyManager) partyListings() []PartyListing {
+	listings := make([]PartyListing, 0)
+	for _, p := range pm.Parties {
+		if p.Listed && !p.Locked {
+			listings = append(listings, p.listing())
+		}
+	}
+	slices.SortFunc(listings, func(a, b PartyListing) int {
+		if a.Members != b.Members {
+			return b.Members - a.Members
+		}
+		return strings.Compare(string(a.PartyID), string(b.PartyID))
+	})
+	return listings
+}
+
+// sendListings sends the page of listings matching the filter.
+func (pm *PartyManager) sendListings(c *Client, listings []PartyListing, f PartyListingFilter) {
+	page, total := f.page(listings)
+	c.SendMessage(ServerMessagePartyList, ServerMessagePartyListPayload{
+		Parties: page,
+		Total:   total,
+		Offset:  max(f.Offset, 0),
+	})
+}
+
+// publishListings pushes the listings to every subscribed browser if a
+// handler marked them changed since they were last published.
+func (pm *PartyManager) publishListings() {
+	if !pm.listingsChanged {
+		return
+	}
+	pm.listingsChanged = false
+	if len(pm.browsers) == 0 {
+		pm.listings = nil
+		return
+	}
+	listings := pm.partyListings()
+	if pm.listings != nil && slices.Equal(listings, pm.listings) {
+		return
+	}
+	pm.listings = listings
+	for _, b := range pm.browsers {
+		pm.sendListings(b.client, listings, b.filter)
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Longest chat message accepted, in characters.
+	maxChatLength = 200
+
+	// A client may send at most chatRateLimit messages within chatRateWindow.
+	chatRateLimit  = 5
+	chatRateWindow = 5 * time.Second
+
+	// Number of recent messages a Party keeps for clients that rejoin.
+	chatHistorySize = 20
+)
+
+// ChatMessage is a chat message as it is sent to the members of a Party.
+// Time is the server time it was received at, in Unix milliseconds.
+type ChatMessage struct {
+	SenderID ClientID `json:"senderId"`
+	PlayerProfile
+	Text string `json:"text"`
+	Time int64  `json:"time"`
+}
+
+// ChatFilter moderates chat messages before they are sent to a Party. It
+// returns the text to send, which may be rewritten, or false to drop the
+// message.
+//
+// Filters are called from the PartyManager goroutine.
+type ChatFilter interface {
+	Filter(sender ClientID, text string) (string, bool)
+}
+
+// ChatFilterFunc lets an ordinary function act as a ChatFilter.
+type ChatFilterFunc func(sender ClientID, text string) (string, bool)
+
+func (f ChatFilterFunc) Filter(sender ClientID, text string) (string, bool) {
+	return f(sender, text)
+}
+
+// WordFilter masks blocked words with asterisks. Words are matched whole
+// and regardless of case.
+type WordFilter struct {
+	blocked map[string]bool
+}
+
+// NewWordFilter creates a WordFilter blocking the given words.
+func NewWordFilter(words ...string) *WordFilter {
+	wf := &WordFilter{blocked: make(map[string]bool, len(words))}
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			wf.blocked[strings.ToLower(w)] = true
+		}
+	}
+	return wf
+}
+
+func (wf *WordFilter) Filter(_ ClientID, text string) (string, bool) {
+	var b strings.Builder
+	word := -1 // start of the current word
+	flush := func(end int) {
+		if word < 0 {
+			return
+		}
+		if w := text[word:end]; wf.blocked[strings.ToLower(w)] {
+			b.WriteString(strings.Repeat("*", utf8.RuneCountInString(w)))
+		} else {
+			b.WriteString(w)
+		}
+		word = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if word < 0 {
+				word = i
+			}
+			continue
+		}
+		flush(i)
+		b.WriteRune(r)
+	}
+	flush(len(text))
+	return b.String(), true
+}
+
+// validChatText trims a chat message and reports whether it may be sent.
+func validChatText(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || utf8.RuneCountInString(text) > maxChatLength {
+		return text, false
+	}
+	for _, r := range text {
+		if r < ' ' || r == utf8.RuneError {
+			return text, false
+		}
+	}
+	return text, true
+}
@@ -21,8 +21,9 @@ const (
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+	// Maximum message size allowed from peer. Fits a chat message of
+	// maxChatLength multi-byte characters.
+	maxMessageSize = 1024
 
 	// Size of the client's send buffer
 	sendBufferSize = 6
@@ -43,14 +44,24 @@ func NewSecretKey() SecretKey {
 	return SecretKey(uuid.New().String())
 }
 
+// Client is a player's connection. Its profile and rate limiters are only
+// used by the PartyManager goroutine once the pumps run.
 type Client struct {
-	ID     ClientID
-	Secret SecretKey
-	conn   *websocket.Conn
-	send   chan ServerMessage
-	pm     *PartyManager
-	game   *Game
-	mu     sync.Mutex
+	ID      ClientID
+	Secret  SecretKey
+	Player  PlayerKey // lasts across connections, unlike ID
+	conn    *websocket.Conn
+	send    chan ServerMessage // of this connection, read by writePump
+	out     *outbox            // guarded by mu, shared on reconnect
+	pm      *PartyManager
+	game    *Game
+	clock   *clockSync // guarded by mu, replaced on reconnect
+	rtt     *latency   // guarded by mu, replaced on reconnect
+	profile PlayerProfile
+	region  string // declared when joining the public queue
+	chat    rateLimiter
+	joins   rateLimiter // attempts to join with a password
+	mu      sync.Mutex
 }
 
 var upgrader = websocket.Upgrader{
@@ -60,24 +71,50 @@ var upgrader = websocket.Upgrader{
 
 // ServeWs is the main entrypoint of a client. It creates the Client object and
 // starts the read and write pumps.
+//
+// The optional "name" and "avatar" query parameters set the client's
+// PlayerProfile. An invalid profile is reported and replaced by a default.
+// The optional "playerKey" parameter is the PlayerKey the client was given
+// on an earlier connection.
 func ServeWs(pm *PartyManager, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
+	query := r.URL.Query()
 	c := &Client{
 		ID:     NewClientID(),
 		Secret: NewSecretKey(),
+		Player: parsePlayerKey(query.Get("playerKey")),
 		conn:   conn,
 		send:   make(chan ServerMessage, sendBufferSize),
 		pm:     pm,
+		clock:  newClockSync(),
+		rtt:    newLatency(),
+	}
+
+	var profileErr error
+	profile := PlayerProfile{DisplayName: query.Get("name"), Avatar: query.Get("avatar")}.Normalize()
+	if !profile.IsZero() {
+		if profileErr = profile.Validate(); profileErr == nil {
+			c.profile = profile
+		}
 	}
 
 	go c.writePump()
 	go c.readPump()
 
-	c.SendMessage(ServerMessageConnectSuccess, ServerMessageConnectSuccessPayload{ClientID: c.ID, SecretKey: c.Secret})
+	c.SendMessage(ServerMessageConnectSuccess, ServerMessageConnectSuccessPayload{
+		ClientID:      c.ID,
+		SecretKey:     c.Secret,
+		PlayerKey:     c.Player,
+		PlayerProfile: c.Profile(),
+	})
+	if profileErr != nil {
+		c.SendError(ErrorCodeInvalidProfile, "Invalid profile: "+profileErr.Error()+".", "")
+	}
+	go c.syncClock(clockSyncBurst)
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -96,8 +133,10 @@ func (c *Client) readPump() {
 	}()
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(data string) error {
+		now := time.Now()
+		c.conn.SetReadDeadline(now.Add(pongWait))
+		c.rtt.addPong([]byte(data), now)
 		return nil
 	})
 
@@ -108,6 +147,7 @@ func (c *Client) readPump() {
 			log.Printf("connection closed: %v", err)
 			break
 		}
+		receivedAt := time.Now()
 
 		payload, err := UnmarshalClientMessage(msg)
 		if err != nil {
@@ -119,8 +159,26 @@ func (c *Client) readPump() {
 		case ClientMessageJoin:
 			if p, ok := payload.(ClientMessageJoinPayload); ok {
 				c.pm.SendCommand(PartyManagerCommand{
-					Type:    PartyManagerCommandAddClient,
-					Payload: PartyManagerAddClientPayload{Client: c, ClientID: p.ClientID, PartyID: p.PartyID, SecretKey: p.SecretKey},
+					Type: PartyManagerCommandAddClient,
+					Payload: PartyManagerAddClientPayload{
+						Client:     c,
+						ClientID:   p.ClientID,
+						PartyID:    p.PartyID,
+						InviteCode: p.InviteCode,
+						SecretKey:  p.SecretKey,
+						LastSeq:    p.LastSeq,
+						Password:   p.Password,
+						Profile:    p.PlayerProfile,
+						Queue:      p.Queue,
+						Region:     p.Region,
+					},
+				})
+			}
+		case ClientMessageCreateParty:
+			if p, ok := payload.(ClientMessageCreatePartyPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandCreateParty,
+					Payload: PartyManagerCreatePartyPayload{Client: c, Profile: p.PlayerProfile},
 				})
 			}
 		case ClientMessageLeave:
@@ -130,11 +188,102 @@ func (c *Client) readPump() {
 					Payload: PartyManagerRemoveClientPayload{Client: c},
 				})
 			}
+		case ClientMessageCancelQueue:
+			if _, ok := payload.(ClientMessageCancelQueuePayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandCancelQueue,
+					Payload: PartyManagerCancelQueuePayload{Client: c},
+				})
+			}
+		case ClientMessageListQueues:
+			if _, ok := payload.(ClientMessageListQueuesPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandListQueues,
+					Payload: PartyManagerListQueuesPayload{Client: c},
+				})
+			}
+		case ClientMessageSnapshot:
+			if _, ok := payload.(ClientMessageSnapshotPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandSnapshot,
+					Payload: PartyManagerSnapshotPayload{Client: c},
+				})
+			}
+		case ClientMessageKickMember:
+			if p, ok := payload.(ClientMessageKickMemberPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandKickClient,
+					Payload: PartyManagerKickClientPayload{Client: c, TargetID: p.ClientID},
+				})
+			}
+		case ClientMessageBanMember:
+			if p, ok := payload.(ClientMessageBanMemberPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandKickClient,
+					Payload: PartyManagerKickClientPayload{Client: c, TargetID: p.ClientID, Ban: true},
+				})
+			}
+		case ClientMessageSetProfile:
+			if p, ok := payload.(ClientMessageSetProfilePayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandSetProfile,
+					Payload: PartyManagerSetProfilePayload{Client: c, Profile: p.PlayerProfile},
+				})
+			}
+		case ClientMessageChat:
+			if p, ok := payload.(ClientMessageChatPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandChat,
+					Payload: PartyManagerChatPayload{Client: c, Text: p.Text, ReceivedAt: receivedAt},
+				})
+			}
+		case ClientMessageUpdateParty:
+			if p, ok := payload.(ClientMessageUpdatePartyPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandUpdateParty,
+					Payload: PartyManagerUpdatePartyPayload{Client: c, Update: p.PartySettingsUpdate},
+				})
+			}
+		case ClientMessageListParties:
+			if p, ok := payload.(ClientMessageListPartiesPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandListParties,
+					Payload: PartyManagerListPartiesPayload{Client: c, Filter: p.PartyListingFilter, Subscribe: p.Subscribe},
+				})
+			}
+		case ClientMessageRematchVote:
+			if p, ok := payload.(ClientMessageRematchVotePayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandRematchVote,
+					Payload: PartyManagerRematchVotePayload{Client: c, Vote: p.Vote},
+				})
+			}
+		case ClientMessageSetReady:
+			if p, ok := payload.(ClientMessageSetReadyPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandSetReady,
+					Payload: PartyManagerSetReadyPayload{Client: c, Ready: p.Ready},
+				})
+			}
+		case ClientMessageTransferHost:
+			if p, ok := payload.(ClientMessageTransferHostPayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandTransferHost,
+					Payload: PartyManagerTransferHostPayload{Client: c, TargetID: p.ClientID},
+				})
+			}
 		case ClientMessageStartGame:
-			if _, ok := payload.(ClientMessageStartGamePayload); ok {
+			if p, ok := payload.(ClientMessageStartGamePayload); ok {
 				c.pm.SendCommand(PartyManagerCommand{
 					Type:    PartyManagerCommandStartGame,
-					Payload: PartyManagerStartGamePayload{Client: c},
+					Payload: PartyManagerStartGamePayload{Client: c, Settings: p.Settings, ReadyCheck: p.ReadyCheck},
+				})
+			}
+		case ClientMessageEndGame:
+			if _, ok := payload.(ClientMessageEndGamePayload); ok {
+				c.pm.SendCommand(PartyManagerCommand{
+					Type:    PartyManagerCommandEndGame,
+					Payload: PartyManagerEndGamePayload{Client: c},
 				})
 			}
 		case ClientMessagePlayerAction:
@@ -151,11 +300,39 @@ func (c *Client) readPump() {
 				game.SendCommand(GameCommand{
 					Type: GameCommandPlayerAction,
 					Payload: GameCommandPlayerActionPayload{
-						ClientID: c.ID,
-						Action:   p.Action,
+						ClientID:   c.ID,
+						Action:     p.Action,
+						ClientTime: p.ClientTime,
+						ReceivedAt: receivedAt,
+					},
+				})
+			}
+		case ClientMessageSubmitAnswer:
+			if p, ok := payload.(ClientMessageSubmitAnswerPayload); ok {
+				c.mu.Lock()
+				game := c.game
+				c.mu.Unlock()
+
+				if game == nil {
+					c.SendError(ErrorCodeNotInGame, "Not in a game.", msg.Type)
+					continue
+				}
+
+				game.SendCommand(GameCommand{
+					Type: GameCommandSubmitAnswer,
+					Payload: GameCommandSubmitAnswerPayload{
+						ClientID:   c.ID,
+						Round:      p.Round,
+						Choice:     p.Choice,
+						ClientTime: p.ClientTime,
+						ReceivedAt: receivedAt,
 					},
 				})
 			}
+		case ClientMessageClockPong:
+			if p, ok := payload.(ClientMessageClockPongPayload); ok {
+				c.handleClockPong(p, receivedAt)
+			}
 		default:
 			c.SendError(ErrorCodeInvalidRequest, "Unknown request.", msg.Type)
 		}
@@ -169,10 +346,17 @@ func (c *Client) readPump() {
 // executing all writes from this goroutine.
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
+	clockTicker := time.NewTicker(clockSyncPeriod)
 	defer func() {
 		ticker.Stop()
+		clockTicker.Stop()
 		c.conn.Close()
 	}()
+
+	// Measure the round trip time right away, for matchmaking
+	if err := c.ping(); err != nil {
+		return
+	}
 	for {
 		select {
 		case message, ok := <-c.send:
@@ -198,12 +382,104 @@ func (c *Client) writePump() {
 				return
 			}
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.ping(); err != nil {
 				return
 			}
+		case <-clockTicker.C:
+			// Keep the clock estimate fresh while it matters
+			c.mu.Lock()
+			inGame := c.game != nil
+			c.mu.Unlock()
+			if inGame {
+				c.SendMessage(ServerMessageClockPing, c.currentClock().newPing(time.Now()))
+			}
+		}
+	}
+}
+
+// ping writes a websocket ping, whose pong measures the round trip time.
+// It must only be called by writePump.
+func (c *Client) ping() error {
+	now := time.Now()
+	c.conn.SetWriteDeadline(now.Add(writeWait))
+	return c.conn.WriteMessage(websocket.PingMessage, c.rtt.pingData(now))
+}
+
+// RTT returns the client's round trip time, measured by websocket pings,
+// if any pong arrived yet.
+func (c *Client) RTT() (time.Duration, bool) {
+	c.mu.Lock()
+	rtt := c.rtt
+	c.mu.Unlock()
+	if rtt == nil {
+		return 0, false
+	}
+	return rtt.get()
+}
+
+// rttMeasured returns a channel that is closed once the client's round
+// trip time is measured, or nil if it never will be.
+func (c *Client) rttMeasured() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rtt == nil {
+		return nil
+	}
+	return c.rtt.measuredCh
+}
+
+// syncClock sends n clockPings, spaced out so they are not queued behind
+// each other.
+func (c *Client) syncClock(n int) {
+	for i := range n {
+		if i > 0 {
+			time.Sleep(clockSyncSpacing)
 		}
+		c.SendMessage(ServerMessageClockPing, c.currentClock().newPing(time.Now()))
+	}
+}
+
+// handleClockPong adds a clock sample and reports the updated estimate to
+// the client, so it can relate server timestamps to its own clock.
+func (c *Client) handleClockPong(p ClientMessageClockPongPayload, receivedAt time.Time) {
+	est, ok := c.currentClock().addSample(p, receivedAt)
+	if !ok {
+		c.SendError(ErrorCodeInvalidRequest, "Unknown clock ping.", ClientMessageClockPong)
+		return
+	}
+	c.SendMessage(ServerMessageClockSync, ServerMessageClockSyncPayload{
+		OffsetMs: est.Offset.Milliseconds(),
+		RTTMs:    est.RTT.Milliseconds(),
+	})
+}
+
+// Profile returns how the client is shown to others, falling back to a
+// default name if none was chosen.
+func (c *Client) Profile() PlayerProfile {
+	if c.profile.DisplayName == "" {
+		profile := defaultProfile(c.ID)
+		profile.Avatar = c.profile.Avatar
+		return profile
+	}
+	return c.profile
+}
+
+// currentClock returns the client's clockSync, which a reconnect may
+// replace while the pumps of the earlier connection still run.
+func (c *Client) currentClock() *clockSync {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clock
+}
+
+// ClockEstimate returns the estimate of the client's clock, if the client
+// has answered any clockPing.
+func (c *Client) ClockEstimate() (ClockEstimate, bool) {
+	clock := c.currentClock()
+	if clock == nil {
+		return ClockEstimate{}, false
 	}
+	return clock.estimate()
 }
 
 func (c *Client) SendMessage(msgType ServerMessageType, payload any) {
@@ -212,8 +488,17 @@ func (c *Client) SendMessage(msgType ServerMessageType, payload any) {
 		log.Printf("SendMessage: failed to marshal payload for client %s: %v (msgType=%s)", c.ID, err, msgType)
 		return
 	}
+	msg := ServerMessage{Type: msgType, Payload: bytes}
+
+	out := c.outbox()
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if sequenced(msgType) {
+		// Kept even if dropped below, so a reconnect can replay it
+		msg = out.replay.add(msg)
+	}
 	select {
-	case c.send <- ServerMessage{Type: msgType, Payload: bytes}:
+	case out.send <- msg:
 	default:
 		log.Printf("Send buffer full for %s", c.ID)
 	}
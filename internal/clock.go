@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Number of clock samples kept per client.
+	clockSyncSamples = 8
+
+	// Pings sent right after a client connects, and the gap between them.
+	clockSyncBurst   = 3
+	clockSyncSpacing = 200 * time.Millisecond
+
+	// Send a ping with this period while the client is in a game.
+	clockSyncPeriod = 15 * time.Second
+
+	// Upper bound on the latency a client's inputs are corrected for, so a
+	// client cannot gain time by delaying its pongs.
+	maxLatencyCorrection = 500 * time.Millisecond
+)
+
+// ClockEstimate is the estimated relation between a client's clock and the
+// server's clock. Offset is the client's clock minus the server's clock.
+type ClockEstimate struct {
+	Offset time.Duration
+	RTT    time.Duration
+}
+
+// clockSync runs the NTP-style exchange with one client. The server sends a
+// clockPing, the client answers with a clockPong holding its local time,
+// and each round trip yields one sample.
+//
+// The sample with the lowest RTT is trusted most, since it was least
+// affected by queuing delays. It is safe for concurrent use.
+type clockSync struct {
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]time.Time
+	samples []ClockEstimate
+}
+
+// newClockSync creates a clockSync without samples.
+func newClockSync() *clockSync {
+	return &clockSync{pending: make(map[uint64]time.Time)}
+}
+
+// newPing records an outstanding ping and returns its payload.
+func (cs *clockSync) newPing(now time.Time) ServerMessageClockPingPayload {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	// Forget pings that were never answered
+	if len(cs.pending) >= clockSyncSamples {
+		clear(cs.pending)
+	}
+	cs.nextID++
+	cs.pending[cs.nextID] = now
+	return ServerMessageClockPingPayload{ID: cs.nextID, ServerTime: now.UnixMilli()}
+}
+
+// addSample completes the round trip for a pong received at now. It
+// returns false if the pong does not answer an outstanding ping.
+func (cs *clockSync) addSample(pong ClientMessageClockPongPayload, now time.Time) (ClockEstimate, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	sentAt, ok := cs.pending[pong.ID]
+	if !ok {
+		return ClockEstimate{}, false
+	}
+	delete(cs.pending, pong.ID)
+
+	rtt := now.Sub(sentAt)
+	clientTime := time.UnixMilli(pong.ClientTime)
+	sample := ClockEstimate{
+		Offset: clientTime.Sub(sentAt.Add(rtt / 2)),
+		RTT:    rtt,
+	}
+	cs.samples = append(cs.samples, sample)
+	if len(cs.samples) > clockSyncSamples {
+		cs.samples = cs.samples[1:]
+	}
+	return cs.bestLocked(), true
+}
+
+// estimate returns the current best estimate, if any sample exists.
+func (cs *clockSync) estimate() (ClockEstimate, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if len(cs.samples) == 0 {
+		return ClockEstimate{}, false
+	}
+	return cs.bestLocked(), true
+}
+
+// bestLocked returns the sample with the lowest RTT.
+// cs.mu must be held and at least one sample must exist.
+func (cs *clockSync) bestLocked() ClockEstimate {
+	best := cs.samples[0]
+	for _, s := range cs.samples[1:] {
+		if s.RTT < best.RTT {
+			best = s
+		}
+	}
+	return best
+}
+
+// correctInputTime estimates when a player responded, expressed as the
+// server time at which the stimulus would have had to be sent for the
+// response to take that long. Subtracting the stimulus send time from the
+// result gives a reaction time independent of the player's latency.
+//
+// With a client timestamp the press time is converted to server time and
+// the stimulus' one-way delay (half the RTT) is removed. Without one, the
+// whole RTT is removed from the time the input was received. The press
+// time is kept within the window allowed by the measured RTT.
+func correctInputTime(receivedAt time.Time, clientTime int64, est ClockEstimate) time.Time {
+	rtt := min(est.RTT, maxLatencyCorrection)
+	pressedAt := receivedAt.Add(-rtt / 2)
+	if clientTime > 0 {
+		pressedAt = time.UnixMilli(clientTime).Add(-est.Offset)
+	}
+
+	if earliest := receivedAt.Add(-rtt); pressedAt.Before(earliest) {
+		pressedAt = earliest
+	}
+	if pressedAt.After(receivedAt) {
+		pressedAt = receivedAt
+	}
+	return pressedAt.Add(-rtt / 2)
+}
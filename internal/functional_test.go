@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,23 +19,32 @@ const timeout = 2 * time.Second
 
 // TestClient wraps all session info needed to verify and reconnect a client.
 type TestClient struct {
-	Conn      *websocket.Conn
-	ID        ClientID
-	SecretKey SecretKey
-	PartyID   PartyID
+	Conn       *websocket.Conn
+	ID         ClientID
+	SecretKey  SecretKey
+	PartyID    PartyID
+	InviteCode InviteCode
 }
 
 type joinPayload struct {
-	ClientID string `json:"clientId"`
-	PartyID  string `json:"partyId"`
-	Secret   string `json:"secret,omitempty"`
+	ClientID    string `json:"clientId"`
+	PartyID     string `json:"partyId"`
+	InviteCode  string `json:"inviteCode,omitempty"`
+	Secret      string `json:"secret,omitempty"`
+	Password    string `json:"password,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	LastSeq     uint64 `json:"lastSeq,omitempty"`
+	Queue       string `json:"queue,omitempty"`
+	Region      string `json:"region,omitempty"`
 }
 
 // startTestServer starts a WebSocket server.
 // returns the websocket server and its PartyManager.
-func startTestServer(t *testing.T) (*httptest.Server, *PartyManager) {
+func startTestServer(t *testing.T, opts ...PartyManagerOption) (*httptest.Server, *PartyManager) {
 	t.Helper()
-	pm := NewPartyManagerWithTimeouts(100*time.Millisecond, 50*time.Millisecond)
+	pm := NewPartyManagerWithTimeouts(100*time.Millisecond, 50*time.Millisecond, opts...)
+	pm.ReadyCheckTimeout = 300 * time.Millisecond
+	pm.RematchWindow = 300 * time.Millisecond
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		ServeWs(pm, w, r)
@@ -46,8 +56,18 @@ func startTestServer(t *testing.T) (*httptest.Server, *PartyManager) {
 
 // wsDial connects to the test WebSocket endpoint and returns the connection.
 func wsDial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	return wsDialQuery(t, srv, "")
+}
+
+// wsDialQuery connects to the test WebSocket endpoint with the given
+// query string and returns the connection.
+func wsDialQuery(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
 	t.Helper()
 	wsURL := httpToWs(t, srv.URL+"/ws")
+	if query != "" {
+		wsURL += "?" + query
+	}
 	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
 	if err != nil {
 		t.Fatalf("dial failed: %v", err)
@@ -84,7 +104,9 @@ func expectMessageType(t *testing.T, conn *websocket.Conn, target ServerMessageT
 		}
 
 		// Skip background noise
-		if msg.Type == ServerMessageMemberUpdate || msg.Type == ServerMessageQueueJoined {
+		if msg.Type == ServerMessageMemberUpdate || msg.Type == ServerMessageQueueJoined ||
+			msg.Type == ServerMessageQueueStatus ||
+			msg.Type == ServerMessageClockPing || msg.Type == ServerMessageClockSync {
 			continue
 		}
 
@@ -132,6 +154,39 @@ func connectAndJoin(t *testing.T, srv *httptest.Server, jp joinPayload) *TestCli
 	}
 }
 
+// connectAndCreateParty handles connecting, connectSuccess, and creating
+// a private party hosted by the new client.
+func connectAndCreateParty(t *testing.T, srv *httptest.Server) *TestClient {
+	t.Helper()
+	conn := wsDial(t, srv)
+
+	msgSuccess := expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	payloadAny, err := UnmarshalServerMessage(msgSuccess)
+	if err != nil {
+		t.Fatalf("failed to unmarshal connectSuccess: %v", err)
+	}
+	success := payloadAny.(ServerMessageConnectSuccessPayload)
+
+	sendMessage(t, conn, ClientMessage{Type: ClientMessageCreateParty, Payload: json.RawMessage(`{}`)})
+	msg := expectMessageType(t, conn, ServerMessagePartyCreated, timeout)
+	payloadAny, err = UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal partyCreated: %v", err)
+	}
+	created := payloadAny.(ServerMessagePartyCreatedPayload)
+
+	// Drain the MemberUpdate broadcast when creating
+	_ = expectMessageType(t, conn, ServerMessageMemberUpdate, timeout)
+
+	return &TestClient{
+		Conn:       conn,
+		ID:         ClientID(success.ClientID),
+		SecretKey:  success.SecretKey,
+		PartyID:    created.PartyID,
+		InviteCode: created.InviteCode,
+	}
+}
+
 // connectAndJoinFail handles connecting, receiving connectSuccess,
 // attempting to join a party, and expecting an error response.
 // It returns the error message received.
@@ -859,6 +914,22 @@ func TestInvalidPlayerAction(t *testing.T) {
 	}
 }
 
+// TestSubmitAnswerWithoutRound verifies that an answer naming no round is
+// rejected as malformed, not flagged as cheating.
+func TestSubmitAnswerWithoutRound(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, clientA.Conn, ServerMessageGameStarted, timeout)
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageSubmitAnswer, Payload: json.RawMessage(`{"choice":3}`)})
+	expectErrorCode(t, clientA.Conn, ErrorCodeInvalidRequest)
+}
+
 // TestPlayerActionAfterGameEnd verifies actions are rejected after game ends
 func TestPlayerActionAfterGameEnd(t *testing.T) {
 	srv, _ := startTestServer(t)
@@ -897,13 +968,13 @@ func TestPublicQueueJoin(t *testing.T) {
 	defer clientA.Conn.Close()
 
 	// Should have created a public party
-	if pm.PublicParty == nil {
+	p, exists := pm.Parties[clientA.PartyID]
+	if !exists {
 		t.Fatal("public party should be created when first client joins queue")
 	}
 
-	if clientA.PartyID != pm.PublicParty.ID {
-		t.Fatalf("client should join public party, got %s, expected %s",
-			clientA.PartyID, pm.PublicParty.ID)
+	if p.Private {
+		t.Fatalf("client should join a public party, got private party %s", p.ID)
 	}
 }
 
@@ -920,7 +991,7 @@ func TestPublicQueueFull(t *testing.T) {
 	}
 
 	// Get current public party ID before new client joins
-	currentPublicPartyID := pm.PublicParty.ID
+	currentPublicPartyID := clients[0].PartyID
 
 	// Next client should create a new party since public is full
 	clientExtra := connectAndJoin(t, srv, joinPayload{})
@@ -931,9 +1002,9 @@ func TestPublicQueueFull(t *testing.T) {
 		t.Fatal("extra client should have created new public party")
 	}
 
-	// Verify public party reference updated
-	if pm.PublicParty.ID != clientExtra.PartyID {
-		t.Fatal("public party reference should have updated to new party")
+	// Verify the new party is public
+	if p, exists := pm.Parties[clientExtra.PartyID]; !exists || p.Private {
+		t.Fatal("extra client should be in a new public party")
 	}
 }
 
@@ -950,7 +1021,7 @@ func TestMultipleClientsPublicQueue(t *testing.T) {
 	}
 
 	// All should be in the same public party
-	publicPartyID := pm.PublicParty.ID
+	publicPartyID := clients[0].PartyID
 	for i, client := range clients {
 		if client.PartyID != publicPartyID {
 			t.Fatalf("client %d should be in public party %s, got %s",
@@ -959,8 +1030,8 @@ func TestMultipleClientsPublicQueue(t *testing.T) {
 	}
 
 	// Verify party has all members
-	if len(pm.PublicParty.Members) != 3 {
-		t.Fatalf("public party should have 3 members, got %d", len(pm.PublicParty.Members))
+	if len(pm.Parties[publicPartyID].Members) != 3 {
+		t.Fatalf("public party should have 3 members, got %d", len(pm.Parties[publicPartyID].Members))
 	}
 }
 
@@ -976,7 +1047,7 @@ func TestPublicQueueBehaviorWhenFull(t *testing.T) {
 		defer client.Conn.Close()
 	}
 
-	firstPartyID := pm.PublicParty.ID
+	firstPartyID := firstPartyClients[0].PartyID
 
 	// Next client should trigger new public party creation
 	clientNew := connectAndJoin(t, srv, joinPayload{})
@@ -987,11 +1058,6 @@ func TestPublicQueueBehaviorWhenFull(t *testing.T) {
 		t.Fatal("new client should be in different party when first is full")
 	}
 
-	// Public party reference should have changed
-	if pm.PublicParty.ID == firstPartyID {
-		t.Fatal("public party reference should have changed to new party")
-	}
-
 	// New client should be the new public party's host
 	newParty := pm.Parties[clientNew.PartyID]
 	if newParty.HostID != clientNew.ID {
@@ -1020,6 +1086,92 @@ func TestPartyAtMaxCapacity(t *testing.T) {
 
 }
 
+// expectNotInParty verifies that a client was left out of every party.
+func expectNotInParty(t *testing.T, tc *TestClient) {
+	t.Helper()
+	sendMessage(t, tc.Conn, ClientMessage{Type: ClientMessageLeave, Payload: json.RawMessage(`{}`)})
+	msg := expectMessageType(t, tc.Conn, ServerMessageError, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	if code := payloadAny.(ServerMessageErrorPayload).Code; code != ErrorCodeNotInSession {
+		t.Fatalf("expected the client to be in no party, got %s", code)
+	}
+}
+
+// TestRejectedJoinLeavesClientOut verifies that a client turned away from a
+// full party, or one playing a game, is not added to it anyway.
+func TestRejectedJoinLeavesClientOut(t *testing.T) {
+	srv, _ := startTestServer(t)
+
+	full := connectAndCreateParty(t, srv)
+	defer full.Conn.Close()
+	for range maxPartySize - 1 {
+		member := connectAndJoin(t, srv, joinPayload{PartyID: string(full.PartyID)})
+		defer member.Conn.Close()
+	}
+	late := connectAndJoinFail(t, srv, joinPayload{PartyID: string(full.PartyID)})
+	defer late.Conn.Close()
+	expectNotInParty(t, late)
+
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer member.Conn.Close()
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, host.Conn, ServerMessageGameStarted, timeout)
+
+	spectator := connectAndJoinFail(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer spectator.Conn.Close()
+	expectNotInParty(t, spectator)
+}
+
+// TestJoinWhileInParty verifies that a client already in a party cannot
+// join a second one.
+func TestJoinWhileInParty(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	defer clientA.Conn.Close()
+	clientB := connectAndCreateParty(t, srv)
+	defer clientB.Conn.Close()
+
+	payload, _ := json.Marshal(joinPayload{PartyID: string(clientB.PartyID)})
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageJoin, Payload: payload})
+	msg := expectMessageType(t, clientA.Conn, ServerMessageError, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	if code := payloadAny.(ServerMessageErrorPayload).Code; code != ErrorCodeAlreadyInParty {
+		t.Fatalf("expected %s, got %s", ErrorCodeAlreadyInParty, code)
+	}
+
+	// B is still alone, so cannot start a game
+	sendMessage(t, clientB.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	msg = expectMessageType(t, clientB.Conn, ServerMessageError, timeout)
+	payloadAny, _ = UnmarshalServerMessage(msg)
+	if code := payloadAny.(ServerMessageErrorPayload).Code; code != ErrorCodeNotEnoughMembers {
+		t.Fatalf("expected %s, got %s", ErrorCodeNotEnoughMembers, code)
+	}
+}
+
+// TestReconnectNamesParty verifies that a client reconnecting without a
+// PartyID is told which party it rejoined.
+func TestReconnectNamesParty(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	_ = expectMessageType(t, host.Conn, ServerMessageMemberUpdate, timeout)
+
+	member.Conn.Close()
+	_ = expectMessageType(t, host.Conn, ServerMessageMemberUpdate, timeout)
+
+	rejoined := connectAndJoin(t, srv, joinPayload{
+		ClientID: string(member.ID),
+		Secret:   string(member.SecretKey),
+	})
+	defer rejoined.Conn.Close()
+	if rejoined.PartyID != host.PartyID {
+		t.Fatalf("expected to rejoin party %s, got %q", host.PartyID, rejoined.PartyID)
+	}
+}
+
 // TestInvalidMessageFormats verifies error handling for malformed messages
 func TestInvalidMessageFormats(t *testing.T) {
 	srv, _ := startTestServer(t)
@@ -1183,3 +1335,1658 @@ func TestCantJoinGamesInProgressFromPartyID(t *testing.T) {
 	clientD := connectAndJoinFail(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
 	defer clientD.Conn.Close()
 }
+
+// TestHostEndsGame verifies that the host can end a game in progress
+// and that everyone is told the host ended it.
+func TestHostEndsGame(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, clientA.Conn, ServerMessageGameStarted, timeout)
+	_ = expectMessageType(t, clientB.Conn, ServerMessageGameStarted, timeout)
+
+	// Non-host cannot end the game
+	sendMessage(t, clientB.Conn, ClientMessage{Type: ClientMessageEndGame, Payload: json.RawMessage(`{}`)})
+	msgErr := expectMessageType(t, clientB.Conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if payloadErr.(ServerMessageErrorPayload).Code != ErrorCodeNotPartyHost {
+		t.Fatalf("expected NotPartyHost error, got %s", payloadErr.(ServerMessageErrorPayload).Code)
+	}
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageEndGame, Payload: json.RawMessage(`{}`)})
+	msg := expectMessageType(t, clientB.Conn, ServerMessageGameOver, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal gameOver: %v", err)
+	}
+	if reason := payloadAny.(ServerMessageGameEndedPayload).Reason; reason != GameEndReasonHostEnded {
+		t.Fatalf("expected reason %s, got %s", GameEndReasonHostEnded, reason)
+	}
+}
+
+// TestStartGameWithSettings verifies that host settings are applied on top
+// of the defaults and echoed back in gameStarted.
+func TestStartGameWithSettings(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	payload := json.RawMessage(`{"settings":{"roundCount":3,"countdownSeconds":1,"roundTypes":["pattern"]}}`)
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: payload})
+
+	msg := expectMessageType(t, clientB.Conn, ServerMessageGameStarted, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal gameStarted: %v", err)
+	}
+	started := payloadAny.(ServerMessageGameStartedPayload)
+	if started.CountdownSeconds != 1 || started.Settings.RoundCount != 3 {
+		t.Fatalf("expected host settings to be applied, got %+v", started.Settings)
+	}
+	if len(started.Settings.RoundTypes) != 1 || started.Settings.RoundTypes[0] != RoundTypePattern {
+		t.Fatalf("expected pattern rounds only, got %v", started.Settings.RoundTypes)
+	}
+	if started.Settings.RoundTimeLimitMs != defaultRoundTimeLimitMs {
+		t.Fatalf("expected omitted settings to keep their defaults, got %+v", started.Settings)
+	}
+}
+
+// TestStartGameWithInvalidSettings verifies that settings outside the
+// server's limits are rejected and no game is started.
+func TestStartGameWithInvalidSettings(t *testing.T) {
+	srv, pm := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	for _, settings := range []string{
+		`{"mode":"juggling"}`,
+		`{"roundCount":-1}`,
+		`{"roundTypes":[]}`,
+		`{"roundTypes":["juggling"]}`,
+		`{"minStimulusDelayMs":3000,"maxStimulusDelayMs":1000}`,
+		`{"roundTimeLimitMs":1}`,
+	} {
+		payload := json.RawMessage(`{"settings":` + settings + `}`)
+		sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: payload})
+
+		msgErr := expectMessageType(t, clientA.Conn, ServerMessageError, timeout)
+		payloadErr, _ := UnmarshalServerMessage(msgErr)
+		if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeInvalidSettings {
+			t.Fatalf("settings %s: expected %s error, got %s", settings, ErrorCodeInvalidSettings, code)
+		}
+	}
+
+	if pm.Parties[clientA.PartyID].game != nil {
+		t.Fatal("game should not start with invalid settings")
+	}
+}
+
+// TestClockSync verifies the clockPing/clockPong exchange and that the
+// server reports the client's clock offset.
+func TestClockSync(t *testing.T) {
+	srv, _ := startTestServer(t)
+	conn := wsDial(t, srv)
+	defer conn.Close()
+
+	_ = expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	msg := expectMessageType(t, conn, ServerMessageClockPing, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal clockPing: %v", err)
+	}
+	ping := payloadAny.(ServerMessageClockPingPayload)
+
+	// Answer as a client whose clock runs a minute ahead
+	offset := time.Minute
+	pong, _ := json.Marshal(ClientMessageClockPongPayload{
+		ID:         ping.ID,
+		ClientTime: time.Now().Add(offset).UnixMilli(),
+	})
+	sendMessage(t, conn, ClientMessage{Type: ClientMessageClockPong, Payload: pong})
+
+	msg = expectMessageType(t, conn, ServerMessageClockSync, timeout)
+	payloadAny, err = UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal clockSync: %v", err)
+	}
+	sync := payloadAny.(ServerMessageClockSyncPayload)
+	if d := time.Duration(sync.OffsetMs)*time.Millisecond - offset; d < -time.Second || d > time.Second {
+		t.Fatalf("expected offset near %v, got %dms", offset, sync.OffsetMs)
+	}
+
+	// A pong for an unknown ping is rejected
+	pong, _ = json.Marshal(ClientMessageClockPongPayload{ID: ping.ID, ClientTime: time.Now().UnixMilli()})
+	sendMessage(t, conn, ClientMessage{Type: ClientMessageClockPong, Payload: pong})
+	msgErr := expectMessageType(t, conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeInvalidRequest {
+		t.Fatalf("expected InvalidRequest error, got %s", code)
+	}
+}
+
+// TestCreatePrivateParty verifies that a created party is hosted by its
+// creator, can be joined by ID, and is never filled from the public queue.
+func TestCreatePrivateParty(t *testing.T) {
+	srv, pm := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+
+	// Creating a second party while in one fails
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageCreateParty, Payload: json.RawMessage(`{}`)})
+	msgErr := expectMessageType(t, host.Conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeAlreadyInParty {
+		t.Fatalf("expected AlreadyInParty error, got %s", code)
+	}
+
+	friend := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer friend.Conn.Close()
+	if friend.PartyID != host.PartyID {
+		t.Fatalf("expected friend to join %s, got %s", host.PartyID, friend.PartyID)
+	}
+
+	stranger := connectAndJoin(t, srv, joinPayload{})
+	defer stranger.Conn.Close()
+	if stranger.PartyID == host.PartyID {
+		t.Fatal("public queue should not place clients in a private party")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	p := pm.Parties[host.PartyID]
+	if !p.Private || p.HostID != host.ID || len(p.Members) != 2 {
+		t.Fatalf("expected private party hosted by creator with 2 members, got %+v", p)
+	}
+}
+
+// TestJoinByInviteCode verifies that a private party can be joined by its
+// invite code, regardless of case, and that unknown codes are rejected.
+func TestJoinByInviteCode(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+
+	if len(host.InviteCode) != inviteCodeLength || strings.Trim(string(host.InviteCode), inviteCodeAlphabet) != "" {
+		t.Fatalf("invalid invite code %q", host.InviteCode)
+	}
+
+	friend := connectAndJoin(t, srv, joinPayload{InviteCode: " " + strings.ToLower(string(host.InviteCode))})
+	defer friend.Conn.Close()
+	if friend.PartyID != host.PartyID {
+		t.Fatalf("expected invite code to join %s, got %s", host.PartyID, friend.PartyID)
+	}
+
+	stranger := connectAndJoinFail(t, srv, joinPayload{InviteCode: "ZZZZZZ"})
+	defer stranger.Conn.Close()
+}
+
+// TestInviteCodeFreedOnDisband verifies that the code of a disbanded party
+// no longer joins anything.
+func TestInviteCodeFreedOnDisband(t *testing.T) {
+	srv, pm := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageLeave, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, host.Conn, ServerMessagePartyLeft, timeout)
+
+	if _, exists := pm.InviteCodes[host.InviteCode]; exists {
+		t.Fatal("invite code should be freed when the party disbands")
+	}
+	friend := connectAndJoinFail(t, srv, joinPayload{InviteCode: string(host.InviteCode)})
+	defer friend.Conn.Close()
+}
+
+// TestHostKicksMember verifies that only the host can kick, that the
+// target is told why it left, and that a kicked member may rejoin.
+func TestHostKicksMember(t *testing.T) {
+	srv, pm := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer host.Conn.Close()
+	defer member.Conn.Close()
+
+	kick := func(tc *TestClient, target ClientID) {
+		payload, _ := json.Marshal(ClientMessageKickMemberPayload{ClientID: target})
+		sendMessage(t, tc.Conn, ClientMessage{Type: ClientMessageKickMember, Payload: payload})
+	}
+
+	kick(member, host.ID)
+	msgErr := expectMessageType(t, member.Conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeNotPartyHost {
+		t.Fatalf("expected NotPartyHost error, got %s", code)
+	}
+
+	kick(host, "nobody")
+	msgErr = expectMessageType(t, host.Conn, ServerMessageError, timeout)
+	payloadErr, _ = UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeMemberNotFound {
+		t.Fatalf("expected MemberNotFound error, got %s", code)
+	}
+
+	kick(host, member.ID)
+	msg := expectMessageType(t, member.Conn, ServerMessagePartyLeft, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	if reason := payloadAny.(ServerMessagePartyLeftPayload).Reason; reason != PartyLeftReasonKicked {
+		t.Fatalf("expected reason %s, got %s", PartyLeftReasonKicked, reason)
+	}
+	_ = expectMessageType(t, host.Conn, ServerMessageMemberUpdate, timeout)
+	if _, inParty := pm.Members[member.ID]; inParty {
+		t.Fatal("kicked member should no longer be in a party")
+	}
+
+	payload, _ := json.Marshal(joinPayload{PartyID: string(host.PartyID)})
+	sendMessage(t, member.Conn, ClientMessage{Type: ClientMessageJoin, Payload: payload})
+	_ = expectMessageType(t, member.Conn, ServerMessagePartyJoined, timeout)
+}
+
+// TestHostBansMember verifies that a banned member cannot rejoin.
+func TestHostBansMember(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer host.Conn.Close()
+	defer member.Conn.Close()
+
+	payload, _ := json.Marshal(ClientMessageBanMemberPayload{ClientID: member.ID})
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageBanMember, Payload: payload})
+	_ = expectMessageType(t, member.Conn, ServerMessagePartyLeft, timeout)
+
+	payload, _ = json.Marshal(joinPayload{InviteCode: string(host.InviteCode)})
+	sendMessage(t, member.Conn, ClientMessage{Type: ClientMessageJoin, Payload: payload})
+	msgErr := expectMessageType(t, member.Conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeBanned {
+		t.Fatalf("expected Banned error, got %s", code)
+	}
+}
+
+// TestBannedPlayerRejoins verifies that a ban also applies to the banned
+// player's later connections.
+func TestBannedPlayerRejoins(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+
+	conn := wsDialQuery(t, srv, "")
+	msg := expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	success := payloadAny.(ServerMessageConnectSuccessPayload)
+	sendJoin(t, conn, joinPayload{PartyID: string(host.PartyID)})
+	_ = expectMessageType(t, conn, ServerMessagePartyJoined, timeout)
+
+	payload, _ := json.Marshal(ClientMessageBanMemberPayload{ClientID: ClientID(success.ClientID)})
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageBanMember, Payload: payload})
+	_ = expectMessageType(t, conn, ServerMessagePartyLeft, timeout)
+	conn.Close()
+
+	again := wsDialQuery(t, srv, "playerKey="+string(success.PlayerKey))
+	_ = expectMessageType(t, again, ServerMessageConnectSuccess, timeout)
+	sendJoin(t, again, joinPayload{PartyID: string(host.PartyID)})
+	msgErr := expectMessageType(t, again, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeBanned {
+		t.Fatalf("expected Banned error, got %s", code)
+	}
+}
+
+// TestKickDuringGame verifies that a member kicked mid-game leaves the
+// game for good.
+func TestKickDuringGame(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer host.Conn.Close()
+	defer member.Conn.Close()
+
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, host.Conn, ServerMessageGameStarted, timeout)
+	_ = expectMessageType(t, member.Conn, ServerMessageGameStarted, timeout)
+
+	payload, _ := json.Marshal(ClientMessageKickMemberPayload{ClientID: member.ID})
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageKickMember, Payload: payload})
+	_ = expectMessageType(t, member.Conn, ServerMessagePartyLeft, timeout)
+
+	msg := expectMessageType(t, host.Conn, ServerMessageGameOver, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	if reason := payloadAny.(ServerMessageGameEndedPayload).Reason; reason != GameEndReasonNotEnoughPlayers {
+		t.Fatalf("expected reason %s, got %s", GameEndReasonNotEnoughPlayers, reason)
+	}
+}
+
+// expectHost reads memberUpdates until one names the given host.
+func expectHost(t *testing.T, conn *websocket.Conn, host ClientID) []PartyMemberInfo {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		msg := expectMessageType(t, conn, ServerMessageMemberUpdate, time.Until(deadline))
+		payloadAny, err := UnmarshalServerMessage(msg)
+		if err != nil {
+			t.Fatalf("failed to unmarshal memberUpdate: %v", err)
+		}
+		members := payloadAny.(ServerMessageMemberUpdatePayload).Members
+		for _, m := range members {
+			if m.IsHost && ClientID(m.ID) == host {
+				return members
+			}
+		}
+	}
+	t.Fatalf("timed out waiting for %s to become host", host)
+	return nil
+}
+
+// TestHostSuccessionSkipsDisconnected verifies that the longest-present
+// connected member takes over when the host leaves.
+func TestHostSuccessionSkipsDisconnected(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	clientC := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	clientD := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientC.Conn.Close()
+	defer clientD.Conn.Close()
+
+	// B drops, but stays a member until the abandonment timeout
+	clientB.Conn.Close()
+	time.Sleep(20 * time.Millisecond)
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageLeave, Payload: json.RawMessage(`{}`)})
+	members := expectHost(t, clientD.Conn, clientC.ID)
+
+	want := []ClientID{clientB.ID, clientC.ID, clientD.ID}
+	if len(members) != len(want) {
+		t.Fatalf("expected %d members, got %+v", len(want), members)
+	}
+	for i, m := range members {
+		if ClientID(m.ID) != want[i] {
+			t.Fatalf("expected members in join order %v, got %+v", want, members)
+		}
+	}
+}
+
+// TestTransferHost verifies that the host can hand the party over and that
+// only the host can do so.
+func TestTransferHost(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	transfer := func(tc *TestClient, target ClientID) {
+		payload, _ := json.Marshal(ClientMessageTransferHostPayload{ClientID: target})
+		sendMessage(t, tc.Conn, ClientMessage{Type: ClientMessageTransferHost, Payload: payload})
+	}
+
+	transfer(clientB, clientB.ID)
+	msgErr := expectMessageType(t, clientB.Conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeNotPartyHost {
+		t.Fatalf("expected NotPartyHost error, got %s", code)
+	}
+
+	transfer(clientA, clientB.ID)
+	_ = expectHost(t, clientA.Conn, clientB.ID)
+	_ = expectHost(t, clientB.Conn, clientB.ID)
+
+	// A is no longer allowed to start the game
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	msgErr = expectMessageType(t, clientA.Conn, ServerMessageError, timeout)
+	payloadErr, _ = UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeNotPartyHost {
+		t.Fatalf("expected NotPartyHost error, got %s", code)
+	}
+}
+
+// setReady sends a setReady message for the given client.
+func setReady(t *testing.T, tc *TestClient, ready bool) {
+	t.Helper()
+	payload, _ := json.Marshal(ClientMessageSetReadyPayload{Ready: ready})
+	sendMessage(t, tc.Conn, ClientMessage{Type: ClientMessageSetReady, Payload: payload})
+}
+
+// TestSetReady verifies that ready flags are shown to the whole party.
+func TestSetReady(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+	_ = expectMessageType(t, clientA.Conn, ServerMessageMemberUpdate, timeout)
+
+	setReady(t, clientB, true)
+	msg := expectMessageType(t, clientA.Conn, ServerMessageMemberUpdate, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	for _, m := range payloadAny.(ServerMessageMemberUpdatePayload).Members {
+		if m.IsReady != (ClientID(m.ID) == clientB.ID) {
+			t.Fatalf("expected only B to be ready, got %+v", m)
+		}
+	}
+}
+
+// TestReadyCheckStartsWhenAllReady verifies that a ready check starts the
+// game as soon as every member is ready.
+func TestReadyCheckStartsWhenAllReady(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{"readyCheck":true}`)})
+	_ = expectMessageType(t, clientA.Conn, ServerMessageReadyCheck, timeout)
+	_ = expectMessageType(t, clientB.Conn, ServerMessageReadyCheck, timeout)
+
+	// Well before the ready check times out
+	setReady(t, clientA, true)
+	setReady(t, clientB, true)
+	_ = expectMessageType(t, clientA.Conn, ServerMessageGameStarted, 150*time.Millisecond)
+	_ = expectMessageType(t, clientB.Conn, ServerMessageGameStarted, 150*time.Millisecond)
+}
+
+// TestReadyCheckLeavesOutUnready verifies that members who are not ready
+// when the ready check times out are left out of the game.
+func TestReadyCheckLeavesOutUnready(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	clientC := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+	defer clientC.Conn.Close()
+
+	setReady(t, clientA, true)
+	setReady(t, clientB, true)
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{"readyCheck":true}`)})
+	_ = expectMessageType(t, clientB.Conn, ServerMessageReadyCheck, timeout)
+	_ = expectMessageType(t, clientC.Conn, ServerMessageReadyCheck, timeout)
+	_ = expectMessageType(t, clientB.Conn, ServerMessageGameStarted, timeout)
+
+	// C is not part of the game
+	payload := json.RawMessage(`{"action":"react"}`)
+	sendMessage(t, clientC.Conn, ClientMessage{Type: ClientMessagePlayerAction, Payload: payload})
+	msgErr := expectMessageType(t, clientC.Conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeNotInGame {
+		t.Fatalf("expected NotInGame error, got %s", code)
+	}
+}
+
+// TestReadyCheckNotEnoughReady verifies that no game starts when too few
+// members are ready by the deadline.
+func TestReadyCheckNotEnoughReady(t *testing.T) {
+	srv, pm := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	setReady(t, clientA, true)
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{"readyCheck":true}`)})
+	_ = expectMessageType(t, clientA.Conn, ServerMessageReadyCheck, timeout)
+
+	msgErr := expectMessageType(t, clientA.Conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeNotEnoughMembers {
+		t.Fatalf("expected NotEnoughMembers error, got %s", code)
+	}
+	if pm.Parties[clientA.PartyID].game != nil {
+		t.Fatal("game should not start without enough ready members")
+	}
+}
+
+// TestStartGameCancelsReadyCheck verifies that a plain start replaces a
+// pending ready check, so readying up later does not start a second game.
+func TestStartGameCancelsReadyCheck(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{"readyCheck":true}`)})
+	_ = expectMessageType(t, clientA.Conn, ServerMessageReadyCheck, timeout)
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, clientA.Conn, ServerMessageGameStarted, timeout)
+
+	setReady(t, clientA, true)
+	setReady(t, clientB, true)
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for {
+		clientA.Conn.SetReadDeadline(deadline)
+		_, data, err := clientA.Conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		var msg ServerMessage
+		if err := json.Unmarshal(data, &msg); err == nil && msg.Type == ServerMessageGameStarted {
+			t.Fatal("readying up after a plain start began a second game")
+		}
+	}
+}
+
+// memberNames returns the display names in a memberUpdate, in join order.
+func memberNames(t *testing.T, msg ServerMessage) []string {
+	t.Helper()
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal memberUpdate: %v", err)
+	}
+	var names []string
+	for _, m := range payloadAny.(ServerMessageMemberUpdatePayload).Members {
+		names = append(names, m.DisplayName)
+	}
+	return names
+}
+
+// TestDisplayNameOnConnect verifies that a profile can be chosen when
+// connecting and that invalid ones fall back to a default.
+func TestDisplayNameOnConnect(t *testing.T) {
+	srv, _ := startTestServer(t)
+
+	conn := wsDialQuery(t, srv, "name=Alice&avatar=fox-2")
+	msg := expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	success := payloadAny.(ServerMessageConnectSuccessPayload)
+	if success.DisplayName != "Alice" || success.Avatar != "fox-2" {
+		t.Fatalf("expected chosen profile, got %+v", success.PlayerProfile)
+	}
+
+	conn = wsDialQuery(t, srv, "name=%3Cscript%3E")
+	msg = expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	payloadAny, _ = UnmarshalServerMessage(msg)
+	success = payloadAny.(ServerMessageConnectSuccessPayload)
+	if !strings.HasPrefix(success.DisplayName, "Player ") {
+		t.Fatalf("expected default display name, got %q", success.DisplayName)
+	}
+	msgErr := expectMessageType(t, conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != ErrorCodeInvalidProfile {
+		t.Fatalf("expected InvalidProfile error, got %s", code)
+	}
+}
+
+// TestPlayerKeyOnConnect verifies that a client keeps the PlayerKey it
+// presents when connecting, and is given a new one otherwise.
+func TestPlayerKeyOnConnect(t *testing.T) {
+	srv, _ := startTestServer(t)
+	connectSuccess := func(query string) ServerMessageConnectSuccessPayload {
+		t.Helper()
+		conn := wsDialQuery(t, srv, query)
+		msg := expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+		payloadAny, _ := UnmarshalServerMessage(msg)
+		return payloadAny.(ServerMessageConnectSuccessPayload)
+	}
+
+	first := connectSuccess("")
+	if first.PlayerKey == "" {
+		t.Fatal("expected a player key")
+	}
+	if again := connectSuccess("playerKey=" + string(first.PlayerKey)); again.PlayerKey != first.PlayerKey || again.ClientID == first.ClientID {
+		t.Fatalf("expected the same player key on a new connection, got %+v", again)
+	}
+	if other := connectSuccess("playerKey=not-a-key"); other.PlayerKey == "not-a-key" || other.PlayerKey == first.PlayerKey {
+		t.Fatalf("expected a new player key, got %q", other.PlayerKey)
+	}
+}
+
+// TestDisplayNamesInParty verifies that names are validated, unique within
+// a private party, and shown in memberUpdate.
+func TestDisplayNamesInParty(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+
+	setProfile := func(tc *TestClient, name string) {
+		payload, _ := json.Marshal(ClientMessageSetProfilePayload{PlayerProfile{DisplayName: name}})
+		sendMessage(t, tc.Conn, ClientMessage{Type: ClientMessageSetProfile, Payload: payload})
+	}
+
+	setProfile(host, "Alice")
+	_ = expectMessageType(t, host.Conn, ServerMessageProfileUpdated, timeout)
+	setProfile(host, "this name is far too long to show")
+	expectErrorCode(t, host.Conn, ErrorCodeInvalidProfile)
+
+	dup := connectAndJoinFail(t, srv, joinPayload{PartyID: string(host.PartyID), DisplayName: "ALICE"})
+	defer dup.Conn.Close()
+
+	friend := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID), DisplayName: "Bob"})
+	defer friend.Conn.Close()
+	names := memberNames(t, expectMessageType(t, host.Conn, ServerMessageMemberUpdate, timeout))
+	if len(names) != 2 || names[0] != "Alice" || names[1] != "Bob" {
+		t.Fatalf("expected names [Alice Bob], got %v", names)
+	}
+
+	setProfile(friend, "alice")
+	expectErrorCode(t, friend.Conn, ErrorCodeNameTaken)
+}
+
+// TestDisplayNamesNumberedInQueue verifies that strangers sharing a name
+// in the public queue are told apart.
+func TestDisplayNamesNumberedInQueue(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{DisplayName: "Sam"})
+	clientB := connectAndJoin(t, srv, joinPayload{DisplayName: "Sam"})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	names := memberNames(t, expectMessageType(t, clientA.Conn, ServerMessageMemberUpdate, timeout))
+	if len(names) != 2 || names[0] != "Sam" || names[1] != "Sam 2" {
+		t.Fatalf("expected names [Sam, Sam 2], got %v", names)
+	}
+}
+
+// expectErrorCode waits for an error message and checks its code.
+func expectErrorCode(t *testing.T, conn *websocket.Conn, want ServerErrorCode) {
+	t.Helper()
+	msgErr := expectMessageType(t, conn, ServerMessageError, timeout)
+	payloadErr, _ := UnmarshalServerMessage(msgErr)
+	if code := payloadErr.(ServerMessageErrorPayload).Code; code != want {
+		t.Fatalf("expected %s error, got %s", want, code)
+	}
+}
+
+// sendChat sends a chat message from the given client.
+func sendChat(t *testing.T, tc *TestClient, text string) {
+	t.Helper()
+	payload, _ := json.Marshal(ClientMessageChatPayload{Text: text})
+	sendMessage(t, tc.Conn, ClientMessage{Type: ClientMessageChat, Payload: payload})
+}
+
+// expectChat waits for a chat message and returns it.
+func expectChat(t *testing.T, conn *websocket.Conn) ChatMessage {
+	t.Helper()
+	msg := expectMessageType(t, conn, ServerMessageChat, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal chat: %v", err)
+	}
+	return payloadAny.(ChatMessage)
+}
+
+// TestChatBroadcast verifies that chat messages reach every member of the
+// sender's party, including during a game.
+func TestChatBroadcast(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{DisplayName: "Alice"})
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	sendChat(t, clientA, "  hello  ")
+	for _, tc := range []*TestClient{clientA, clientB} {
+		chat := expectChat(t, tc.Conn)
+		if chat.SenderID != clientA.ID || chat.DisplayName != "Alice" || chat.Text != "hello" || chat.Time == 0 {
+			t.Fatalf("unexpected chat message: %+v", chat)
+		}
+	}
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, clientA.Conn, ServerMessageGameStarted, timeout)
+	_ = expectMessageType(t, clientB.Conn, ServerMessageGameStarted, timeout)
+	sendChat(t, clientB, "gl")
+	if chat := expectChat(t, clientA.Conn); chat.SenderID != clientB.ID || chat.Text != "gl" {
+		t.Fatalf("unexpected chat message during game: %+v", chat)
+	}
+}
+
+// TestChatValidation verifies that empty, overlong and rate limited
+// messages are rejected.
+func TestChatValidation(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+
+	sendChat(t, clientA, "   ")
+	expectErrorCode(t, clientA.Conn, ErrorCodeInvalidRequest)
+	sendChat(t, clientA, strings.Repeat("a", maxChatLength+1))
+	expectErrorCode(t, clientA.Conn, ErrorCodeInvalidRequest)
+
+	for i := 0; i < chatRateLimit; i++ {
+		sendChat(t, clientA, "hi")
+		_ = expectChat(t, clientA.Conn)
+	}
+	sendChat(t, clientA, "hi")
+	expectErrorCode(t, clientA.Conn, ErrorCodeRateLimited)
+}
+
+// TestChatFilter verifies that the PartyManager's ChatFilter can rewrite
+// and drop messages.
+func TestChatFilter(t *testing.T) {
+	words := NewWordFilter("darn")
+	srv, _ := startTestServer(t, WithChatFilter(ChatFilterFunc(func(sender ClientID, text string) (string, bool) {
+		if strings.Contains(text, "spam") {
+			return "", false
+		}
+		return words.Filter(sender, text)
+	})))
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+
+	sendChat(t, clientA, "Darn it, darnit darn!")
+	if chat := expectChat(t, clientA.Conn); chat.Text != "**** it, darnit ****!" {
+		t.Fatalf("expected blocked words to be masked, got %q", chat.Text)
+	}
+	sendChat(t, clientA, "buy spam")
+	expectErrorCode(t, clientA.Conn, ErrorCodeMessageRejected)
+}
+
+// TestChatHistoryOnReconnect verifies that a client rejoining its party
+// receives the recent chat.
+func TestChatHistoryOnReconnect(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientB.Conn.Close()
+
+	sendChat(t, clientB, "before")
+	_ = expectChat(t, clientA.Conn)
+	clientA.Conn.Close()
+	time.Sleep(5 * time.Millisecond)
+	sendChat(t, clientB, "while away")
+	_ = expectChat(t, clientB.Conn)
+	_ = expectChat(t, clientB.Conn)
+
+	clientA2 := connectAndJoin(t, srv, joinPayload{
+		ClientID: string(clientA.ID),
+		PartyID:  string(clientA.PartyID),
+		Secret:   string(clientA.SecretKey),
+	})
+	defer clientA2.Conn.Close()
+
+	history := expectSnapshot(t, clientA2.Conn).Chat
+	if len(history) != 2 || history[0].Text != "before" || history[1].Text != "while away" {
+		t.Fatalf("expected both messages in history, got %+v", history)
+	}
+}
+
+// playAndEndGame starts a game with the given settings, has the host end
+// it, and drains gameOver on every client.
+func playAndEndGame(t *testing.T, host *TestClient, others []*TestClient, settings string) {
+	t.Helper()
+	payload := json.RawMessage(`{"settings":` + settings + `}`)
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: payload})
+	all := append([]*TestClient{host}, others...)
+	for _, tc := range all {
+		_ = expectMessageType(t, tc.Conn, ServerMessageGameStarted, timeout)
+	}
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageEndGame, Payload: json.RawMessage(`{}`)})
+	for _, tc := range all {
+		_ = expectMessageType(t, tc.Conn, ServerMessageGameOver, timeout)
+	}
+}
+
+// voteRematch sends a rematch vote from the given client.
+func voteRematch(t *testing.T, tc *TestClient, vote bool) {
+	t.Helper()
+	payload, _ := json.Marshal(ClientMessageRematchVotePayload{Vote: vote})
+	sendMessage(t, tc.Conn, ClientMessage{Type: ClientMessageRematchVote, Payload: payload})
+}
+
+// expectRematch waits for a rematch tally and returns it.
+func expectRematch(t *testing.T, conn *websocket.Conn) ServerMessageRematchPayload {
+	t.Helper()
+	msg := expectMessageType(t, conn, ServerMessageRematch, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal rematch: %v", err)
+	}
+	return payloadAny.(ServerMessageRematchPayload)
+}
+
+// TestRematchVoteStartsGame verifies that a rematch starts with the same
+// settings once enough members agree.
+func TestRematchVoteStartsGame(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndCreateParty(t, srv)
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	playAndEndGame(t, clientA, []*TestClient{clientB}, `{"roundCount":3}`)
+	for _, tc := range []*TestClient{clientA, clientB} {
+		if tally := expectRematch(t, tc.Conn); !tally.Open || tally.Needed != 2 || len(tally.Yes) != 0 {
+			t.Fatalf("expected an open vote needing 2, got %+v", tally)
+		}
+	}
+
+	voteRematch(t, clientB, true)
+	if tally := expectRematch(t, clientA.Conn); len(tally.Yes) != 1 || tally.Yes[0] != clientB.ID {
+		t.Fatalf("expected B's vote in the tally, got %+v", tally)
+	}
+	voteRematch(t, clientA, true)
+	if tally := expectRematch(t, clientA.Conn); tally.Open || len(tally.Yes) != 2 {
+		t.Fatalf("expected the vote to close with 2 votes, got %+v", tally)
+	}
+
+	msg := expectMessageType(t, clientA.Conn, ServerMessageGameStarted, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	if started := payloadAny.(ServerMessageGameStartedPayload); started.Settings.RoundCount != 3 {
+		t.Fatalf("expected the rematch to keep the settings, got %+v", started.Settings)
+	}
+}
+
+// TestRematchVoteFails verifies that no rematch starts if too few members
+// agree before the window closes.
+func TestRematchVoteFails(t *testing.T) {
+	srv, pm := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	clientB := connectAndJoin(t, srv, joinPayload{PartyID: string(clientA.PartyID)})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	playAndEndGame(t, clientA, []*TestClient{clientB}, `{}`)
+	_ = expectRematch(t, clientA.Conn)
+	voteRematch(t, clientA, true)
+	_ = expectRematch(t, clientA.Conn)
+
+	// The window closes with a single vote
+	if tally := expectRematch(t, clientA.Conn); tally.Open || len(tally.Yes) != 1 {
+		t.Fatalf("expected the vote to close with 1 vote, got %+v", tally)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(pm.Games) != 0 {
+		t.Fatalf("expected no rematch, got %d games", len(pm.Games))
+	}
+
+	for range 3 {
+		_ = expectRematch(t, clientB.Conn)
+	}
+	voteRematch(t, clientB, true)
+	expectErrorCode(t, clientB.Conn, ErrorCodeNoRematch)
+}
+
+// setListed has the host list or unlist their party.
+func setListed(t *testing.T, host *TestClient, listed bool) {
+	t.Helper()
+	payload, _ := json.Marshal(map[string]any{"listed": listed})
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageUpdateParty, Payload: payload})
+	_ = expectMessageType(t, host.Conn, ServerMessagePartySettings, timeout)
+}
+
+// listParties requests a page of the party browser and returns it.
+func listParties(t *testing.T, conn *websocket.Conn, req ClientMessageListPartiesPayload) ServerMessagePartyListPayload {
+	t.Helper()
+	payload, _ := json.Marshal(req)
+	sendMessage(t, conn, ClientMessage{Type: ClientMessageListParties, Payload: payload})
+	return expectPartyList(t, conn)
+}
+
+// expectPartyList waits for a page of the party browser.
+func expectPartyList(t *testing.T, conn *websocket.Conn) ServerMessagePartyListPayload {
+	t.Helper()
+	msg := expectMessageType(t, conn, ServerMessagePartyList, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal partyList: %v", err)
+	}
+	return payloadAny.(ServerMessagePartyListPayload)
+}
+
+// TestPartyBrowserUpdates verifies that subscribed browsers are sent the
+// listings whenever they change.
+func TestPartyBrowserUpdates(t *testing.T) {
+	srv, _ := startTestServer(t)
+	browser := wsDial(t, srv)
+	_ = expectMessageType(t, browser, ServerMessageConnectSuccess, timeout)
+
+	if list := listParties(t, browser, ClientMessageListPartiesPayload{Subscribe: true}); list.Total != 0 {
+		t.Fatalf("expected no listed parties, got %+v", list)
+	}
+
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	setListed(t, host, true)
+	list := expectPartyList(t, browser)
+	if list.Total != 1 || list.Parties[0].PartyID != host.PartyID || list.Parties[0].Members != 1 ||
+		list.Parties[0].Mode != GameModeClassic || list.Parties[0].HostName == "" {
+		t.Fatalf("expected the host's party to be listed, got %+v", list)
+	}
+
+	friend := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer friend.Conn.Close()
+	if list := expectPartyList(t, browser); list.Parties[0].Members != 2 {
+		t.Fatalf("expected the listing to show 2 members, got %+v", list)
+	}
+
+	setListed(t, host, false)
+	if list := expectPartyList(t, browser); list.Total != 0 {
+		t.Fatalf("expected the party to be unlisted, got %+v", list)
+	}
+
+	// Public parties are never listed
+	public := connectAndJoin(t, srv, joinPayload{})
+	defer public.Conn.Close()
+	payload := json.RawMessage(`{"listed":true}`)
+	sendMessage(t, public.Conn, ClientMessage{Type: ClientMessageUpdateParty, Payload: payload})
+	expectErrorCode(t, public.Conn, ErrorCodeInvalidRequest)
+}
+
+// TestPartyBrowserFilters verifies filtering and pagination of listings.
+func TestPartyBrowserFilters(t *testing.T) {
+	srv, _ := startTestServer(t)
+	hostA := connectAndCreateParty(t, srv)
+	hostB := connectAndCreateParty(t, srv)
+	defer hostA.Conn.Close()
+	defer hostB.Conn.Close()
+	setListed(t, hostA, true)
+	setListed(t, hostB, true)
+	friend := connectAndJoin(t, srv, joinPayload{PartyID: string(hostB.PartyID)})
+	defer friend.Conn.Close()
+
+	browser := wsDial(t, srv)
+	_ = expectMessageType(t, browser, ServerMessageConnectSuccess, timeout)
+
+	list := listParties(t, browser, ClientMessageListPartiesPayload{PartyListingFilter: PartyListingFilter{Limit: 1}})
+	if list.Total != 2 || len(list.Parties) != 1 || list.Parties[0].PartyID != hostB.PartyID {
+		t.Fatalf("expected the fuller party on the first page, got %+v", list)
+	}
+	list = listParties(t, browser, ClientMessageListPartiesPayload{PartyListingFilter: PartyListingFilter{Limit: 1, Offset: 1}})
+	if list.Total != 2 || len(list.Parties) != 1 || list.Parties[0].PartyID != hostA.PartyID {
+		t.Fatalf("expected the other party on the second page, got %+v", list)
+	}
+
+	sendMessage(t, hostB.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, hostB.Conn, ServerMessageGameStarted, timeout)
+	list = listParties(t, browser, ClientMessageListPartiesPayload{PartyListingFilter: PartyListingFilter{NotInGame: true}})
+	if list.Total != 1 || list.Parties[0].PartyID != hostA.PartyID {
+		t.Fatalf("expected parties in game to be filtered out, got %+v", list)
+	}
+	list = listParties(t, browser, ClientMessageListPartiesPayload{PartyListingFilter: PartyListingFilter{Mode: "duel"}})
+	if list.Total != 0 {
+		t.Fatalf("expected no parties of another mode, got %+v", list)
+	}
+
+	_ = updateParty(t, hostA, `{"password":"hunter2"}`)
+	list = listParties(t, browser, ClientMessageListPartiesPayload{PartyListingFilter: PartyListingFilter{NoPassword: true}})
+	if list.Total != 1 || list.Parties[0].PartyID != hostB.PartyID {
+		t.Fatalf("expected password protected parties to be filtered out, got %+v", list)
+	}
+	_ = updateParty(t, hostB, `{"locked":true}`)
+	if list = listParties(t, browser, ClientMessageListPartiesPayload{}); list.Total != 1 || !list.Parties[0].PasswordProtected {
+		t.Fatalf("expected only the password protected party, got %+v", list)
+	}
+}
+
+// updateParty sends the host's party settings and returns the settings
+// broadcast in response.
+func updateParty(t *testing.T, host *TestClient, update string) PartySettings {
+	t.Helper()
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageUpdateParty, Payload: json.RawMessage(update)})
+	msg := expectMessageType(t, host.Conn, ServerMessagePartySettings, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal partySettings: %v", err)
+	}
+	return payloadAny.(PartySettings)
+}
+
+// sendJoin sends a join request on an open connection.
+func sendJoin(t *testing.T, conn *websocket.Conn, jp joinPayload) {
+	t.Helper()
+	payload, _ := json.Marshal(jp)
+	sendMessage(t, conn, ClientMessage{Type: ClientMessageJoin, Payload: payload})
+}
+
+// TestPartyPassword verifies that joining a password protected party
+// needs the password, except for members reconnecting.
+func TestPartyPassword(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+
+	if settings := updateParty(t, host, `{"password":"hunter2"}`); !settings.HasPassword {
+		t.Fatalf("expected the party to have a password, got %+v", settings)
+	}
+
+	guest := wsDial(t, srv)
+	_ = expectMessageType(t, guest, ServerMessageConnectSuccess, timeout)
+	sendJoin(t, guest, joinPayload{PartyID: string(host.PartyID)})
+	expectErrorCode(t, guest, ErrorCodeWrongPassword)
+	sendJoin(t, guest, joinPayload{InviteCode: string(host.InviteCode), Password: "hunter3"})
+	expectErrorCode(t, guest, ErrorCodeWrongPassword)
+	sendJoin(t, guest, joinPayload{PartyID: string(host.PartyID), Password: "hunter2"})
+	_ = expectMessageType(t, guest, ServerMessagePartyJoined, timeout)
+
+	// Members coming back are not asked for the password
+	member.Conn.Close()
+	time.Sleep(5 * time.Millisecond)
+	rejoined := connectAndJoin(t, srv, joinPayload{
+		ClientID: string(member.ID),
+		PartyID:  string(member.PartyID),
+		Secret:   string(member.SecretKey),
+	})
+	defer rejoined.Conn.Close()
+
+	if settings := updateParty(t, host, `{"password":""}`); settings.HasPassword {
+		t.Fatalf("expected the password to be removed, got %+v", settings)
+	}
+	other := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer other.Conn.Close()
+}
+
+// TestPartyPasswordRateLimited verifies that a client trying too many
+// passwords is turned away before its password is checked.
+func TestPartyPasswordRateLimited(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	updateParty(t, host, `{"password":"hunter2"}`)
+
+	guest := wsDial(t, srv)
+	defer guest.Close()
+	_ = expectMessageType(t, guest, ServerMessageConnectSuccess, timeout)
+	for range passwordAttemptLimit {
+		sendJoin(t, guest, joinPayload{PartyID: string(host.PartyID), Password: "hunter3"})
+		expectErrorCode(t, guest, ErrorCodeWrongPassword)
+	}
+	sendJoin(t, guest, joinPayload{PartyID: string(host.PartyID), Password: "hunter2"})
+	expectErrorCode(t, guest, ErrorCodeRateLimited)
+
+	// Others may still try
+	other := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID), Password: "hunter2"})
+	defer other.Conn.Close()
+}
+
+// TestPartyLocked verifies that nobody new can join a locked party.
+func TestPartyLocked(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+
+	if settings := updateParty(t, host, `{"locked":true}`); !settings.Locked {
+		t.Fatalf("expected the party to be locked, got %+v", settings)
+	}
+	guest := wsDial(t, srv)
+	_ = expectMessageType(t, guest, ServerMessageConnectSuccess, timeout)
+	sendJoin(t, guest, joinPayload{PartyID: string(host.PartyID)})
+	expectErrorCode(t, guest, ErrorCodePartyLocked)
+
+	member.Conn.Close()
+	time.Sleep(5 * time.Millisecond)
+	rejoined := connectAndJoin(t, srv, joinPayload{
+		ClientID: string(member.ID),
+		PartyID:  string(member.PartyID),
+		Secret:   string(member.SecretKey),
+	})
+	defer rejoined.Conn.Close()
+
+	_ = updateParty(t, host, `{"locked":false}`)
+	sendJoin(t, guest, joinPayload{PartyID: string(host.PartyID)})
+	_ = expectMessageType(t, guest, ServerMessagePartyJoined, timeout)
+}
+
+// TestPartySizeSettings verifies that hosts can change their party's size
+// within the server's limits.
+func TestPartySizeSettings(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+
+	if settings := updateParty(t, host, `{"maxSize":2}`); settings.MaxSize != 2 || settings.MinSize != minPartySize {
+		t.Fatalf("expected a party of 2 at most, got %+v", settings)
+	}
+	friend := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer friend.Conn.Close()
+	guest := wsDial(t, srv)
+	_ = expectMessageType(t, guest, ServerMessageConnectSuccess, timeout)
+	sendJoin(t, guest, joinPayload{PartyID: string(host.PartyID)})
+	expectErrorCode(t, guest, ErrorCodePartyFull)
+
+	for _, update := range []string{`{"maxSize":1}`, `{"minSize":3}`, `{"maxSize":13}`} {
+		sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageUpdateParty, Payload: json.RawMessage(update)})
+		expectErrorCode(t, host.Conn, ErrorCodeInvalidRequest)
+	}
+
+	_ = updateParty(t, host, `{"minSize":3,"maxSize":8}`)
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	expectErrorCode(t, host.Conn, ErrorCodeNotEnoughMembers)
+}
+
+// TestDuelModePlayerLimits verifies that a game mode's player limits are
+// enforced when starting a game.
+func TestDuelModePlayerLimits(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	friend := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer friend.Conn.Close()
+	third := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+
+	duel := json.RawMessage(`{"settings":{"mode":"duel"}}`)
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: duel})
+	expectErrorCode(t, host.Conn, ErrorCodeTooManyMembers)
+
+	sendMessage(t, third.Conn, ClientMessage{Type: ClientMessageLeave, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, third.Conn, ServerMessagePartyLeft, timeout)
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: duel})
+	_ = expectMessageType(t, friend.Conn, ServerMessageGameStarted, timeout)
+}
+
+// TestPublicGameRated verifies that players of a public game are told
+// their new rating once it ends.
+func TestPublicGameRated(t *testing.T) {
+	srv, pm := startTestServer(t)
+	settings := DefaultGameSettings()
+	settings.RoundCount = 1
+	settings.RoundTypes = []RoundType{RoundTypeReaction}
+	settings.CountdownSeconds = 0
+	settings.MinStimulusDelayMs, settings.MaxStimulusDelayMs = 0, 0
+	settings.MinReactionMs = 0
+	pm.Queues[QueueQuick].Settings = settings
+
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+	defer clientB.Conn.Close()
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	for _, tc := range []*TestClient{clientA, clientB} {
+		_ = expectMessageType(t, tc.Conn, ServerMessageGameStarted, timeout)
+		_ = expectMessageType(t, tc.Conn, ServerMessageRoundStarted, timeout)
+		_ = expectMessageType(t, tc.Conn, ServerMessageStimulus, timeout)
+	}
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessagePlayerAction, Payload: json.RawMessage(`{}`)})
+	time.Sleep(20 * time.Millisecond)
+	sendMessage(t, clientB.Conn, ClientMessage{Type: ClientMessagePlayerAction, Payload: json.RawMessage(`{}`)})
+
+	for _, tc := range []struct {
+		conn *websocket.Conn
+		won  bool
+	}{{clientA.Conn, true}, {clientB.Conn, false}} {
+		_ = expectMessageType(t, tc.conn, ServerMessageRoundResult, timeout)
+		_ = expectMessageType(t, tc.conn, ServerMessageStandings, timeout)
+		_ = expectMessageType(t, tc.conn, ServerMessageGameOver, timeout)
+		msg := expectMessageType(t, tc.conn, ServerMessageRatingUpdate, timeout)
+		payloadAny, _ := UnmarshalServerMessage(msg)
+		update := payloadAny.(ServerMessageRatingUpdatePayload)
+		if tc.won != (update.Change > 0) || update.Rating != defaultRating+update.Change {
+			t.Fatalf("unexpected rating update %+v (won: %v)", update, tc.won)
+		}
+	}
+}
+
+// TestLeaverRated verifies that a player who leaves a public game is rated
+// as losing it.
+func TestLeaverRated(t *testing.T) {
+	srv, pm := startTestServer(t)
+	settings := DefaultGameSettings()
+	settings.Mode = GameModeDuel
+	settings.RoundCount = 3
+	settings.RoundTypes = []RoundType{RoundTypeReaction}
+	settings.CountdownSeconds = 0
+	settings.MinStimulusDelayMs, settings.MaxStimulusDelayMs = 0, 0
+	settings.MinReactionMs = 0
+	pm.Queues[QueueDuel].Settings = settings
+	pm.AutoStartCountdown = 50 * time.Millisecond
+
+	winner := connectAndJoin(t, srv, joinPayload{Queue: QueueDuel})
+	defer winner.Conn.Close()
+	loser := wsDial(t, srv)
+	msg := expectMessageType(t, loser, ServerMessageConnectSuccess, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	loserKey := payloadAny.(ServerMessageConnectSuccessPayload).PlayerKey
+	sendJoin(t, loser, joinPayload{Queue: QueueDuel})
+	_ = expectMessageType(t, loser, ServerMessagePartyJoined, timeout)
+
+	for _, conn := range []*websocket.Conn{winner.Conn, loser} {
+		_ = expectAutoStart(t, conn)
+		_ = expectMessageType(t, conn, ServerMessageGameStarted, timeout)
+		_ = expectMessageType(t, conn, ServerMessageRoundStarted, timeout)
+		_ = expectMessageType(t, conn, ServerMessageStimulus, timeout)
+	}
+	sendMessage(t, winner.Conn, ClientMessage{Type: ClientMessagePlayerAction, Payload: json.RawMessage(`{}`)})
+	time.Sleep(20 * time.Millisecond)
+	sendMessage(t, loser, ClientMessage{Type: ClientMessagePlayerAction, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, loser, ServerMessageRoundResult, timeout)
+
+	_ = expectMessageType(t, winner.Conn, ServerMessageRoundResult, timeout)
+	_ = expectMessageType(t, winner.Conn, ServerMessageStandings, timeout)
+
+	// The losing player quits before the game is decided
+	loser.Close()
+
+	msg = expectMessageType(t, winner.Conn, ServerMessageGameOver, timeout)
+	payloadAny, _ = UnmarshalServerMessage(msg)
+	over := payloadAny.(ServerMessageGameEndedPayload)
+	if last := over.Ranking[len(over.Ranking)-1]; len(over.Ranking) != 2 || !last.Left || over.WinnerID != winner.ID {
+		t.Fatalf("expected the leaver to be ranked last, got %+v", over)
+	}
+	msg = expectMessageType(t, winner.Conn, ServerMessageRatingUpdate, timeout)
+	payloadAny, _ = UnmarshalServerMessage(msg)
+	if update := payloadAny.(ServerMessageRatingUpdatePayload); update.Change <= 0 {
+		t.Fatalf("expected the winner to gain rating, got %+v", update)
+	}
+	if rating := pm.Ratings.Get(loserKey); rating >= defaultRating {
+		t.Fatalf("expected the leaver to lose rating, got %v", rating)
+	}
+}
+
+// TestQueueStatus verifies that queued clients are told their place in
+// the queue.
+func TestQueueStatus(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientB.Conn.Close()
+
+	msg := expectMessageType(t, clientB.Conn, ServerMessageQueueStatus, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	status := payloadAny.(ServerMessageQueueStatusPayload)
+	if status.Position != 2 || status.Waiting != 2 {
+		t.Fatalf("expected position 2 of 2, got %+v", status)
+	}
+}
+
+// TestCancelQueue verifies that a queued client can leave the queue and
+// its public party, and join the queue again.
+func TestCancelQueue(t *testing.T) {
+	srv, pm := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientB.Conn.Close()
+
+	sendMessage(t, clientB.Conn, ClientMessage{Type: ClientMessageCancelQueue, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, clientB.Conn, ServerMessageQueueLeft, timeout)
+	if _, ok := pm.Parties[clientA.PartyID].Members[clientB.ID]; ok {
+		t.Fatal("client should have left its public party")
+	}
+
+	sendMessage(t, clientB.Conn, ClientMessage{Type: ClientMessageCancelQueue, Payload: json.RawMessage(`{}`)})
+	expectErrorCode(t, clientB.Conn, ErrorCodeNotInQueue)
+
+	sendJoin(t, clientB.Conn, joinPayload{})
+	_ = expectMessageType(t, clientB.Conn, ServerMessagePartyJoined, timeout)
+}
+
+// TestCancelQueueInGame verifies that players of a started public game
+// are no longer queued.
+func TestCancelQueueInGame(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientB.Conn.Close()
+
+	sendMessage(t, clientA.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{}`)})
+	_ = expectMessageType(t, clientB.Conn, ServerMessageGameStarted, timeout)
+
+	sendMessage(t, clientB.Conn, ClientMessage{Type: ClientMessageCancelQueue, Payload: json.RawMessage(`{}`)})
+	expectErrorCode(t, clientB.Conn, ErrorCodeNotInQueue)
+}
+
+// expectAutoStart waits for a public party's countdown and returns it.
+func expectAutoStart(t *testing.T, conn *websocket.Conn) ServerMessageAutoStartPayload {
+	t.Helper()
+	msg := expectMessageType(t, conn, ServerMessageAutoStart, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal autoStart: %v", err)
+	}
+	return payloadAny.(ServerMessageAutoStartPayload)
+}
+
+// TestAutoStartWhenFull verifies that a public party starts its game on
+// its own once it is full.
+func TestAutoStartWhenFull(t *testing.T) {
+	srv, pm := startTestServer(t)
+	pm.Queues[QueueQuick].Matchmaker.TargetSize = 2
+	pm.AutoStartCountdown = 50 * time.Millisecond
+
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientB.Conn.Close()
+
+	for _, tc := range []*TestClient{clientA, clientB} {
+		if as := expectAutoStart(t, tc.Conn); as.Cancelled {
+			t.Fatal("countdown should not be cancelled")
+		}
+		_ = expectMessageType(t, tc.Conn, ServerMessageGameStarted, timeout)
+	}
+}
+
+// TestAutoStartAfterFillTimeout verifies that a public party that is not
+// full starts its game once it waited long enough.
+func TestAutoStartAfterFillTimeout(t *testing.T) {
+	srv, pm := startTestServer(t)
+	pm.Queues[QueueQuick].Matchmaker.FillTimeout = 100 * time.Millisecond
+	pm.AutoStartCountdown = 50 * time.Millisecond
+
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientB.Conn.Close()
+
+	_ = expectAutoStart(t, clientA.Conn)
+	_ = expectMessageType(t, clientA.Conn, ServerMessageGameStarted, timeout)
+}
+
+// TestAutoStartCancelled verifies that the countdown stops when too few
+// players are left.
+func TestAutoStartCancelled(t *testing.T) {
+	srv, pm := startTestServer(t)
+	pm.Queues[QueueQuick].Matchmaker.TargetSize = 2
+	pm.AutoStartCountdown = time.Second
+
+	clientA := connectAndJoin(t, srv, joinPayload{})
+	defer clientA.Conn.Close()
+	clientB := connectAndJoin(t, srv, joinPayload{})
+	defer clientB.Conn.Close()
+
+	_ = expectAutoStart(t, clientA.Conn)
+	_ = expectAutoStart(t, clientB.Conn)
+	sendMessage(t, clientB.Conn, ClientMessage{Type: ClientMessageCancelQueue, Payload: json.RawMessage(`{}`)})
+
+	if as := expectAutoStart(t, clientA.Conn); !as.Cancelled {
+		t.Fatal("countdown should be cancelled")
+	}
+	time.Sleep(pm.AutoStartCountdown + 100*time.Millisecond)
+	if pm.Parties[clientA.PartyID].game != nil {
+		t.Fatal("game should not start after the countdown was cancelled")
+	}
+}
+
+// TestRTTMeasured verifies that the websocket pings measure a client's
+// round trip time as soon as it connects.
+func TestRTTMeasured(t *testing.T) {
+	srv, pm := startTestServer(t)
+	client := connectAndJoin(t, srv, joinPayload{})
+	defer client.Conn.Close()
+
+	// The pong is only sent while the test client reads
+	go func() {
+		for {
+			if _, _, err := client.Conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	c := pm.Parties[client.PartyID].Members[client.ID].Client
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, ok := c.RTT(); ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("round trip time was not measured")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRegionsNotMatched verifies that players who declared different
+// regions are placed in different public parties.
+func TestRegionsNotMatched(t *testing.T) {
+	srv, _ := startTestServer(t)
+	clientA := connectAndJoin(t, srv, joinPayload{Region: "eu-west"})
+	defer clientA.Conn.Close()
+	clientB := connectAndJoin(t, srv, joinPayload{Region: "US-East"})
+	defer clientB.Conn.Close()
+	clientC := connectAndJoin(t, srv, joinPayload{Region: "us-east"})
+	defer clientC.Conn.Close()
+
+	if clientA.PartyID == clientB.PartyID {
+		t.Fatal("players of different regions should not be matched")
+	}
+	if clientB.PartyID != clientC.PartyID {
+		t.Fatal("players of the same region should be matched")
+	}
+
+	conn := wsDial(t, srv)
+	defer conn.Close()
+	_ = expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	sendJoin(t, conn, joinPayload{Region: "eu west"})
+	expectErrorCode(t, conn, ErrorCodeInvalidRequest)
+}
+
+// TestQueuesMatchSeparately verifies that each public queue fills its own
+// parties with its own game mode.
+func TestQueuesMatchSeparately(t *testing.T) {
+	srv, pm := startTestServer(t)
+	quick := connectAndJoin(t, srv, joinPayload{})
+	defer quick.Conn.Close()
+	duelA := connectAndJoin(t, srv, joinPayload{Queue: QueueDuel})
+	defer duelA.Conn.Close()
+	duelB := connectAndJoin(t, srv, joinPayload{Queue: QueueDuel})
+	defer duelB.Conn.Close()
+
+	if quick.PartyID == duelA.PartyID {
+		t.Fatal("players of different queues should not be matched")
+	}
+	if duelA.PartyID != duelB.PartyID {
+		t.Fatal("players of the same queue should be matched")
+	}
+	if p := pm.Parties[duelA.PartyID]; p.settings.Mode != GameModeDuel || p.MaxSize != 2 {
+		t.Fatalf("expected a duel party of 2, got mode %s of %d", p.settings.Mode, p.MaxSize)
+	}
+
+	conn := wsDial(t, srv)
+	defer conn.Close()
+	_ = expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	sendJoin(t, conn, joinPayload{Queue: "marathon"})
+	expectErrorCode(t, conn, ErrorCodeQueueNotFound)
+}
+
+// TestPublicPartyKeepsQueue verifies that a public party started by its
+// host plays its queue's game, and still belongs to the queue afterwards.
+func TestPublicPartyKeepsQueue(t *testing.T) {
+	srv, pm := startTestServer(t)
+	pm.AutoStartCountdown = time.Second
+
+	host := connectAndJoin(t, srv, joinPayload{Queue: QueueDuel})
+	defer host.Conn.Close()
+	guest := connectAndJoin(t, srv, joinPayload{Queue: QueueDuel})
+	defer guest.Conn.Close()
+	_ = expectAutoStart(t, host.Conn)
+
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: json.RawMessage(`{"settings":{"mode":"classic","roundCount":3}}`)})
+	if as := expectAutoStart(t, host.Conn); !as.Cancelled {
+		t.Fatal("starting the game should cancel the countdown")
+	}
+	msg := expectMessageType(t, host.Conn, ServerMessageGameStarted, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	if started := payloadAny.(ServerMessageGameStartedPayload); started.Settings.Mode != GameModeDuel {
+		t.Fatalf("expected the queue's duel settings, got %+v", started.Settings)
+	}
+
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageSnapshot, Payload: json.RawMessage(`{}`)})
+	if snap := expectSnapshot(t, host.Conn); snap.Queue != QueueDuel {
+		t.Fatalf("expected the party to stay in queue %s, got %q", QueueDuel, snap.Queue)
+	}
+}
+
+// TestListQueues verifies that clients can see how busy each public
+// queue is.
+func TestListQueues(t *testing.T) {
+	srv, _ := startTestServer(t)
+	client := connectAndJoin(t, srv, joinPayload{Queue: QueuePatternMarathon})
+	defer client.Conn.Close()
+
+	sendMessage(t, client.Conn, ClientMessage{Type: ClientMessageListQueues, Payload: json.RawMessage(`{}`)})
+	msg := expectMessageType(t, client.Conn, ServerMessageQueueList, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	queues := payloadAny.(ServerMessageQueueListPayload).Queues
+	if len(queues) != 4 {
+		t.Fatalf("expected 4 queues, got %+v", queues)
+	}
+	for _, q := range queues {
+		want := 0
+		if q.Name == QueuePatternMarathon {
+			want = 1
+		}
+		if q.Waiting != want {
+			t.Fatalf("expected %d waiting in %s, got %d", want, q.Name, q.Waiting)
+		}
+	}
+}
+
+// disconnectAfterChat has host say something, and closes the connection
+// of member once it heard it. It returns the Seq of that chat message.
+func disconnectAfterChat(t *testing.T, host, member *TestClient) uint64 {
+	t.Helper()
+	sendChat(t, host, "before")
+	_ = expectChat(t, host.Conn)
+	lastSeq := expectMessageType(t, member.Conn, ServerMessageChat, timeout).Seq
+	if lastSeq == 0 {
+		t.Fatal("expected the chat message to be numbered")
+	}
+	member.Conn.Close()
+	_ = expectMessageType(t, host.Conn, ServerMessageMemberUpdate, timeout)
+	return lastSeq
+}
+
+// TestReconnectReplaysMissedMessages verifies that a reconnecting client
+// is sent the messages it missed while it was away.
+func TestReconnectReplaysMissedMessages(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	lastSeq := disconnectAfterChat(t, host, member)
+
+	sendChat(t, host, "missed")
+	_ = expectChat(t, host.Conn)
+
+	conn := wsDial(t, srv)
+	defer conn.Close()
+	if success := expectMessageType(t, conn, ServerMessageConnectSuccess, timeout); success.Seq != 0 {
+		t.Fatalf("expected connectSuccess to be unnumbered, got seq %d", success.Seq)
+	}
+	sendJoin(t, conn, joinPayload{ClientID: string(member.ID), Secret: string(member.SecretKey), LastSeq: lastSeq})
+
+	msg := expectMessageType(t, conn, ServerMessageReplay, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	missed := payloadAny.(ServerMessageReplayPayload).Messages
+
+	// The member was also told it disconnected, before the chat message
+	if len(missed) != 2 || missed[1].Type != ServerMessageChat {
+		t.Fatalf("expected the missed chat message, got %+v", missed)
+	}
+	for i, m := range missed {
+		if m.Seq != lastSeq+uint64(i)+1 {
+			t.Fatalf("expected message %d to have seq %d, got %d", i, lastSeq+uint64(i)+1, m.Seq)
+		}
+	}
+	joined := expectMessageType(t, conn, ServerMessagePartyJoined, timeout)
+	if joined.Seq != lastSeq+3 {
+		t.Fatalf("expected numbering to continue at %d, got %d", lastSeq+3, joined.Seq)
+	}
+	snap := expectMessageType(t, conn, ServerMessageSnapshot, timeout)
+
+	// Errors about the new connection's own messages continue the numbering
+	sendMessage(t, conn, ClientMessage{Type: ClientMessageChat, Payload: json.RawMessage(`"missed"`)})
+	if msgErr := expectMessageType(t, conn, ServerMessageError, timeout); msgErr.Seq != snap.Seq+1 {
+		t.Fatalf("expected the error to have seq %d, got %d", snap.Seq+1, msgErr.Seq)
+	}
+}
+
+// TestReconnectResync verifies that a client that missed more messages
+// than are kept is told to resync.
+func TestReconnectResync(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	lastSeq := disconnectAfterChat(t, host, member)
+
+	conn := wsDial(t, srv)
+	defer conn.Close()
+	_ = expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	sendJoin(t, conn, joinPayload{ClientID: string(member.ID), Secret: string(member.SecretKey), LastSeq: lastSeq + 10})
+
+	msg := expectMessageType(t, conn, ServerMessageResync, timeout)
+	payloadAny, _ := UnmarshalServerMessage(msg)
+	// The member was also told it disconnected
+	if seq := payloadAny.(ServerMessageResyncPayload).Seq; seq != lastSeq+1 {
+		t.Fatalf("expected resync from %d, got %d", lastSeq+1, seq)
+	}
+	_ = expectMessageType(t, conn, ServerMessagePartyJoined, timeout)
+}
+
+// expectSnapshot waits for a snapshot message and returns it.
+func expectSnapshot(t *testing.T, conn *websocket.Conn) ServerMessageSnapshotPayload {
+	t.Helper()
+	msg := expectMessageType(t, conn, ServerMessageSnapshot, timeout)
+	payloadAny, err := UnmarshalServerMessage(msg)
+	if err != nil {
+		t.Fatalf("failed to unmarshal snapshot: %v", err)
+	}
+	return payloadAny.(ServerMessageSnapshotPayload)
+}
+
+// TestReconnectSnapshot verifies that a client reconnecting during a game
+// is sent the complete state of its party and game.
+func TestReconnectSnapshot(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	sendChat(t, host, "hello")
+	_ = expectChat(t, member.Conn)
+
+	payload := json.RawMessage(`{"settings":{"roundCount":3,"countdownSeconds":5}}`)
+	sendMessage(t, host.Conn, ClientMessage{Type: ClientMessageStartGame, Payload: payload})
+	_ = expectMessageType(t, member.Conn, ServerMessageGameStarted, timeout)
+	member.Conn.Close()
+	_ = expectMessageType(t, host.Conn, ServerMessageMemberUpdate, timeout)
+
+	conn := wsDial(t, srv)
+	defer conn.Close()
+	_ = expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	sendJoin(t, conn, joinPayload{ClientID: string(member.ID), Secret: string(member.SecretKey)})
+	_ = expectMessageType(t, conn, ServerMessagePartyJoined, timeout)
+
+	snap := expectSnapshot(t, conn)
+	if snap.PartyID != host.PartyID || len(snap.Members) != 2 {
+		t.Fatalf("expected both members of the party, got %+v", snap)
+	}
+	if len(snap.Chat) != 1 || snap.Chat[0].Text != "hello" {
+		t.Fatalf("expected the chat history, got %+v", snap.Chat)
+	}
+	g := snap.Game
+	if g == nil || g.Settings.RoundCount != 3 || len(g.Players) != 2 {
+		t.Fatalf("expected the game in progress, got %+v", g)
+	}
+	if g.Round == nil || g.Round.Phase != string(roundPhaseCountdown) || g.Round.Deadline <= g.Timestamp {
+		t.Fatalf("expected the countdown and its end, got %+v", g.Round)
+	}
+}
+
+// TestRequestSnapshot verifies that members can ask for the state of their
+// party at any time, and others are told they have none.
+func TestRequestSnapshot(t *testing.T) {
+	srv, _ := startTestServer(t)
+	host := connectAndCreateParty(t, srv)
+	defer host.Conn.Close()
+	member := connectAndJoin(t, srv, joinPayload{PartyID: string(host.PartyID)})
+	defer member.Conn.Close()
+
+	sendMessage(t, member.Conn, ClientMessage{Type: ClientMessageSnapshot, Payload: json.RawMessage(`{}`)})
+	snap := expectSnapshot(t, member.Conn)
+	if snap.PartyID != host.PartyID || snap.InviteCode == "" || !snap.Private || len(snap.Members) != 2 {
+		t.Fatalf("expected the private party of both members, got %+v", snap)
+	}
+	if snap.Game != nil || snap.ReadyCheck != nil || snap.Rematch != nil {
+		t.Fatalf("expected an idle party, got %+v", snap)
+	}
+
+	conn := wsDial(t, srv)
+	defer conn.Close()
+	_ = expectMessageType(t, conn, ServerMessageConnectSuccess, timeout)
+	sendMessage(t, conn, ClientMessage{Type: ClientMessageSnapshot, Payload: json.RawMessage(`{}`)})
+	expectErrorCode(t, conn, ErrorCodeNotInSession)
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"encoding/json"
 	"log"
+	"slices"
 	"sync"
 	"time"
 
@@ -24,7 +25,10 @@ const (
 	GameCommandStartGame        GameCommandType = "startGame"
 	GameCommandEndGame          GameCommandType = "endGame"
 	GameCommandPlayerAction     GameCommandType = "playerAction"
+	GameCommandSubmitAnswer     GameCommandType = "submitAnswer"
 	GameCommandClientDisconnect GameCommandType = "clientDisconnect"
+	GameCommandTimer            GameCommandType = "timer"
+	GameCommandSnapshot         GameCommandType = "snapshot"
 )
 
 // GameCommand represents a single instruction sent to a Game
@@ -34,10 +38,40 @@ type GameCommand struct {
 	Payload any
 }
 
-// GameCommandPlayerActionPayload carries player action data
+// GameEndReason explains why a Game ended.
+type GameEndReason string
+
+const (
+	GameEndReasonCompleted        GameEndReason = "completed"
+	GameEndReasonNotEnoughPlayers GameEndReason = "notEnoughPlayers"
+	GameEndReasonHostEnded        GameEndReason = "hostEnded"
+)
+
+// GameCommandEndGamePayload carries the reason a Game is ending.
+// A GameCommandEndGame without a payload is treated as ended by the host.
+type GameCommandEndGamePayload struct {
+	Reason GameEndReason
+}
+
+// GameCommandPlayerActionPayload carries player action data.
+// ReceivedAt is when the server read the action off the connection;
+// ClientTime is the client's own timestamp, if it sent one.
 type GameCommandPlayerActionPayload struct {
-	ClientID ClientID
-	Action   string
+	ClientID   ClientID
+	Action     string
+	ClientTime int64
+	ReceivedAt time.Time
+}
+
+// GameCommandSubmitAnswerPayload carries a puzzle answer.
+// ReceivedAt is when the server read the answer off the connection;
+// ClientTime is the client's own timestamp, if it sent one.
+type GameCommandSubmitAnswerPayload struct {
+	ClientID   ClientID
+	Round      int
+	Choice     int
+	ClientTime int64
+	ReceivedAt time.Time
 }
 
 // GameCommandClientDisconnectPayload carries disconnect data
@@ -45,6 +79,19 @@ type GameCommandClientDisconnectPayload struct {
 	ClientID ClientID
 }
 
+// GameCommandTimerPayload identifies a timer set by Game.schedule.
+// Timers replaced by a later call to schedule are stale and ignored.
+type GameCommandTimerPayload struct {
+	Seq  uint64
+	Name string
+}
+
+// GameCommandSnapshotPayload asks the Game goroutine to call Send with a
+// GameSnapshot, or with nil if the Game ends first.
+type GameCommandSnapshotPayload struct {
+	Send func(*GameSnapshot)
+}
+
 // GameEventType defines supported GameEvent kinds.
 type GameEventType string
 
@@ -55,9 +102,12 @@ const (
 
 // GameEvent represents an event sent from a Game to the PartyManager
 // once key lifecycle transitions occur.
+//
+// GameEventEnded carries the final Ranking, unless no round was scored.
 type GameEvent struct {
-	Type   GameEventType
-	GameID GameID
+	Type    GameEventType
+	GameID  GameID
+	Ranking []PlayerStanding
 }
 
 // Game controls the runtime session between Clients once a Party starts.
@@ -66,23 +116,52 @@ type GameEvent struct {
 //
 // Each Game instance owns its client references and sends
 // outbound server messages via Client.SendMessage.
+//
+// The rules are left to a GameMode. Game state (mode, scores, timer) is
+// only touched by the Game goroutine. Timers never mutate state directly;
+// they deliver a GameCommandTimer.
 type Game struct {
 	ID       GameID
 	Clients  map[ClientID]*Client
 	pm       *PartyManager
 	p        *Party
 	commands chan GameCommand
+	done     chan struct{} // closed once Run returns
 	mu       sync.RWMutex
+	keys     map[ClientID]PlayerKey // everyone who started, for rating
+
+	mode       GameMode
+	settings   GameSettings
+	minPlayers int
+	scores     *scoreboard
+	cheats     *antiCheat
+	timer      *time.Timer
+	timerSeq   uint64
+	timerAt    time.Time // when the pending timer fires
 }
 
 // NewGame creates a new Game and initializes its command channel.
-func NewGame(pm *PartyManager, p *Party, clients map[ClientID]*Client) *Game {
+// The settings are expected to be validated by the caller.
+func NewGame(pm *PartyManager, p *Party, clients map[ClientID]*Client, mode GameMode, settings GameSettings) *Game {
+	profiles := make(map[ClientID]PlayerProfile, len(clients))
+	keys := make(map[ClientID]PlayerKey, len(clients))
+	for cid, c := range clients {
+		profiles[cid] = c.Profile()
+		keys[cid] = c.Player
+	}
 	return &Game{
-		ID:       NewGameID(),
-		Clients:  clients,
-		pm:       pm,
-		p:        p,
-		commands: make(chan GameCommand, 64),
+		ID:         NewGameID(),
+		Clients:    clients,
+		pm:         pm,
+		p:          p,
+		commands:   make(chan GameCommand, 64),
+		done:       make(chan struct{}),
+		keys:       keys,
+		mode:       mode,
+		settings:   settings,
+		minPlayers: p.PlayerLimits(settings).Min,
+		scores:     newScoreboard(profiles),
+		cheats:     newAntiCheat(settings),
 	}
 }
 
@@ -94,11 +173,19 @@ func (g *Game) Start() {
 // Run is the main loop of the Game.
 // It processes incoming commands until a GameCommandEndGame is received.
 func (g *Game) Run() {
-	defer close(g.commands)
+	defer close(g.done)
 
 	for cmd := range g.commands {
 		if g.handleCommand(cmd) {
-			return
+			break
+		}
+	}
+	close(g.commands)
+
+	// Snapshots asked for as the Game ended still get an answer
+	for cmd := range g.commands {
+		if cmd.Type == GameCommandSnapshot {
+			cmd.Payload.(GameCommandSnapshotPayload).Send(nil)
 		}
 	}
 }
@@ -109,8 +196,10 @@ func (g *Game) handleCommand(cmd GameCommand) bool {
 	switch cmd.Type {
 	case GameCommandStartGame:
 		g.broadcast(ServerMessageGameStarted, ServerMessageGameStartedPayload{
-			CountdownSeconds: 3,
+			CountdownSeconds: g.settings.CountdownSeconds,
 			Timestamp:        time.Now().UnixMilli(),
+			Settings:         g.settings,
+			Players:          g.players(),
 		})
 
 		g.pm.GameEvents <- GameEvent{
@@ -118,20 +207,49 @@ func (g *Game) handleCommand(cmd GameCommand) bool {
 			GameID: g.ID,
 		}
 
+		g.mode.Start(g)
+
 	case GameCommandEndGame:
-		g.broadcast(ServerMessageGameOver, ServerMessageGameEndedPayload{
-			Reason:   "manualEnd",
-			WinnerID: "",
-		})
-		g.pm.GameEvents <- GameEvent{
-			Type:   GameEventEnded,
-			GameID: g.ID,
+		reason := GameEndReasonHostEnded
+		if pl, ok := cmd.Payload.(GameCommandEndGamePayload); ok {
+			reason = pl.Reason
 		}
+		g.end(reason)
 		return true
 
 	case GameCommandPlayerAction:
 		pl := cmd.Payload.(GameCommandPlayerActionPayload)
 		log.Printf("Game %s: Player %s action: %s", g.ID, pl.ClientID, pl.Action)
+		g.handleInput(PlayerInput{
+			ClientID:   pl.ClientID,
+			Kind:       ClientMessagePlayerAction,
+			Action:     pl.Action,
+			ClientTime: pl.ClientTime,
+			ReceivedAt: pl.ReceivedAt,
+		})
+
+	case GameCommandSubmitAnswer:
+		pl := cmd.Payload.(GameCommandSubmitAnswerPayload)
+		g.handleInput(PlayerInput{
+			ClientID:   pl.ClientID,
+			Kind:       ClientMessageSubmitAnswer,
+			Round:      pl.Round,
+			Choice:     pl.Choice,
+			ClientTime: pl.ClientTime,
+			ReceivedAt: pl.ReceivedAt,
+		})
+
+	case GameCommandTimer:
+		pl := cmd.Payload.(GameCommandTimerPayload)
+		if pl.Seq != g.timerSeq {
+			return false // stale timer
+		}
+		g.mode.Timer(g, pl.Name)
+
+	case GameCommandSnapshot:
+		pl := cmd.Payload.(GameCommandSnapshotPayload)
+		snap := g.snapshot()
+		pl.Send(&snap)
 
 	case GameCommandClientDisconnect:
 		pl := cmd.Payload.(GameCommandClientDisconnectPayload)
@@ -141,27 +259,230 @@ func (g *Game) handleCommand(cmd GameCommand) bool {
 		g.mu.Unlock()
 
 		// End game if not enough players
-		if clientCount < minPartySize {
-			return g.handleCommand(GameCommand{Type: GameCommandEndGame})
+		if clientCount < g.minPlayers {
+			return g.handleCommand(GameCommand{
+				Type:    GameCommandEndGame,
+				Payload: GameCommandEndGamePayload{Reason: GameEndReasonNotEnoughPlayers},
+			})
 		}
+		g.mode.PlayerLeft(g, pl.ClientID)
 	}
 	return false
 }
 
-// SendCommand safely queues a command for the Game goroutine.
-// If the buffer is full, the command is dropped and logged.
-func (g *Game) SendCommand(cmd GameCommand) {
+// handleInput passes a player's input to the GameMode if the player is
+// still in the Game, correcting its time for the player's latency.
+// Inputs that fail the anti-cheat checks, and all inputs of disqualified
+// players, are dropped.
+func (g *Game) handleInput(in PlayerInput) {
+	g.mu.RLock()
+	c, inGame := g.Clients[in.ClientID]
+	g.mu.RUnlock()
+	if !inGame || g.scores.disqualified[in.ClientID] {
+		return
+	}
+
+	if in.ReceivedAt.IsZero() {
+		in.ReceivedAt = time.Now()
+	}
+	in.At = in.ReceivedAt
+	est, hasEst := c.ClockEstimate()
+	if hasEst {
+		in.At = correctInputTime(in.ReceivedAt, in.ClientTime, est)
+	}
+
+	if reason, detail, cheated := g.cheats.check(in, est, hasEst); cheated {
+		g.reportCheat(in, reason, detail)
+		return
+	}
+	g.mode.PlayerInput(g, in)
+}
+
+// reportCheat flags the player, disqualifies them if the settings say so,
+// and logs a CheatEvent.
+func (g *Game) reportCheat(in PlayerInput, reason CheatReason, detail string) {
+	g.cheats.flag(in.ClientID, reason)
+	if g.settings.DisqualifyCheaters {
+		g.scores.disqualify(in.ClientID)
+	}
+
+	evt, err := json.Marshal(CheatEvent{
+		GameID:       g.ID,
+		PlayerID:     in.ClientID,
+		Reason:       reason,
+		Round:        in.Round,
+		Detail:       detail,
+		Disqualified: g.settings.DisqualifyCheaters,
+		Time:         in.ReceivedAt,
+	})
+	if err != nil {
+		log.Printf("Game %s cheat event marshal error: %v", g.ID, err)
+		return
+	}
+	log.Printf("cheat event: %s", evt)
+}
+
+// markStimulus tells the anti-cheat checks that the stimulus or puzzle of
+// a round was sent at the given time. GameModes must call it for every
+// stimulus they show.
+func (g *Game) markStimulus(round int, kind RoundType, at time.Time) {
+	g.cheats.markStimulus(round, kind, at)
+}
+
+// finish queues the end of the Game. Commands already queued are still
+// handled first.
+func (g *Game) finish(reason GameEndReason) {
+	g.SendCommand(GameCommand{
+		Type:    GameCommandEndGame,
+		Payload: GameCommandEndGamePayload{Reason: reason},
+	})
+}
+
+// end stops the pending timer, broadcasts the final ranking and reports the
+// end of the Game to the PartyManager. Players who left are ranked below
+// those still in the Game, so leaving a game in progress does not spare a
+// player a rated loss. The top ranked player wins once any round was
+// scored.
+func (g *Game) end(reason GameEndReason) {
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.mode.End(g, reason)
+
+	ranking := g.scores.standings(g.playerIDs())
+	left := g.scores.standings(g.leftIDs())
+	for i := range left {
+		left[i].Rank += len(ranking)
+		left[i].Left = true
+	}
+	ranking = append(ranking, left...)
+	g.cheats.annotate(ranking)
+	var winner PlayerStanding
+	var winnerIDs []ClientID
+	if g.scores.rounds > 0 {
+		for _, s := range winners(ranking) {
+			winnerIDs = append(winnerIDs, s.PlayerID)
+		}
+		if len(winnerIDs) > 0 {
+			winner = ranking[0]
+		}
+	}
+
+	g.broadcast(ServerMessageGameOver, ServerMessageGameEndedPayload{
+		WinnerID:   winner.PlayerID,
+		WinnerName: winner.DisplayName,
+		WinnerIDs:  winnerIDs,
+		Reason:     reason,
+		Ranking:    ranking,
+	})
+	evt := GameEvent{
+		Type:   GameEventEnded,
+		GameID: g.ID,
+	}
+	if g.scores.rounds > 0 {
+		evt.Ranking = ranking
+	}
+	g.pm.GameEvents <- evt
+}
+
+// playerIDs returns the IDs of the Clients still in the Game.
+func (g *Game) playerIDs() []ClientID {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	ids := make([]ClientID, 0, len(g.Clients))
+	for cid := range g.Clients {
+		ids = append(ids, cid)
+	}
+	return ids
+}
+
+// leftIDs returns the IDs of the players who started the Game but are no
+// longer in it.
+func (g *Game) leftIDs() []ClientID {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var ids []ClientID
+	for cid := range g.keys {
+		if _, ok := g.Clients[cid]; !ok {
+			ids = append(ids, cid)
+		}
+	}
+	return ids
+}
+
+// players returns the ID and profile of every player in the Game, ordered
+// by ID.
+func (g *Game) players() []PlayerInfo {
+	ids := g.playerIDs()
+	slices.Sort(ids)
+	players := make([]PlayerInfo, len(ids))
+	for i, cid := range ids {
+		players[i] = PlayerInfo{PlayerID: cid, PlayerProfile: g.scores.profiles[cid]}
+	}
+	return players
+}
+
+// schedule arranges for the GameMode's Timer hook to be called with name
+// after d, replacing any pending timer.
+func (g *Game) schedule(d time.Duration, name string) {
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.timerSeq++
+	g.timerAt = time.Now().Add(d)
+	pl := GameCommandTimerPayload{Seq: g.timerSeq, Name: name}
+	g.timer = time.AfterFunc(d, func() {
+		g.SendCommand(GameCommand{Type: GameCommandTimer, Payload: pl})
+	})
+}
+
+// Snapshot has the Game goroutine call send with a GameSnapshot, so that
+// messages sent by send are ordered with those the Game broadcasts. If the
+// Game ends first, send is called with nil instead. Snapshot does not wait
+// for send, and reports false if the Game ended, or its command buffer is
+// full, so send will not be called.
+func (g *Game) Snapshot(send func(*GameSnapshot)) bool {
+	return g.SendCommand(GameCommand{
+		Type:    GameCommandSnapshot,
+		Payload: GameCommandSnapshotPayload{Send: send},
+	})
+}
+
+// snapshot describes the Game as it is now.
+func (g *Game) snapshot() GameSnapshot {
+	s := GameSnapshot{
+		GameID:    g.ID,
+		Settings:  g.settings,
+		Players:   g.players(),
+		Standings: g.scores.standings(g.playerIDs()),
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if m, ok := g.mode.(GameModeSnapshotter); ok {
+		s.Round = m.Snapshot(g)
+	}
+	return s
+}
+
+// SendCommand safely queues a command for the Game goroutine and reports
+// whether it was queued. If the buffer is full, the command is dropped and
+// logged.
+func (g *Game) SendCommand(cmd GameCommand) (queued bool) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("Game %s already closed, ignoring command (%s)",
 				g.ID, cmd.Type)
+			queued = false
 		}
 	}()
 
 	select {
 	case g.commands <- cmd:
+		return true
 	default:
 		log.Printf("Game %s command buffer full (%s)", g.ID, cmd.Type)
+		return false
 	}
 }
 
@@ -177,6 +498,6 @@ func (g *Game) broadcast(msgType ServerMessageType, payload any) {
 	defer g.mu.RUnlock()
 
 	for _, c := range g.Clients {
-		c.SendMessage(msgType, bytes)
+		c.SendMessage(msgType, json.RawMessage(bytes))
 	}
 }
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// GameMode implements the rules of a minigame.
+//
+// The Game owns everything shared between modes: its goroutine, players,
+// timers, scoreboard and ending. It calls the mode's hooks from the Game
+// goroutine only, so a mode can keep its state without locking.
+type GameMode interface {
+	// Start is called once, right after gameStarted is broadcast.
+	Start(g *Game)
+
+	// PlayerInput is called for every action or answer a player sends.
+	PlayerInput(g *Game, in PlayerInput)
+
+	// Timer is called when a timer set with Game.schedule fires and has
+	// not been replaced since.
+	Timer(g *Game, name string)
+
+	// PlayerLeft is called after a player permanently left the Game,
+	// unless the Game is ending because too few players remain.
+	PlayerLeft(g *Game, cid ClientID)
+
+	// End is called once, before the final ranking is broadcast.
+	End(g *Game, reason GameEndReason)
+}
+
+// GameModeSnapshotter is implemented by GameModes that can describe the
+// round in progress to clients redrawing a Game, such as a player who
+// reconnected.
+type GameModeSnapshotter interface {
+	// Snapshot is called on the Game goroutine, like the GameMode hooks.
+	Snapshot(g *Game) *RoundSnapshot
+}
+
+// RoundSnapshot describes the phase a Game is in and its current round,
+// which is 0 during the countdown. Deadline is when the phase ends, in
+// Unix milliseconds, if that is known and may be shown. StimulusAt and
+// Puzzle are set once the stimulus or puzzle of the round was shown, and
+// Responded lists the players who already have a result this round.
+type RoundSnapshot struct {
+	Phase      string     `json:"phase"`
+	Round      int        `json:"round"`
+	RoundType  RoundType  `json:"roundType,omitempty"`
+	Deadline   int64      `json:"deadline,omitempty"`
+	StimulusAt int64      `json:"stimulusAt,omitempty"`
+	Puzzle     *Puzzle    `json:"puzzle,omitempty"`
+	Responded  []ClientID `json:"responded,omitempty"`
+}
+
+// PlayerInput is a player's action or answer as delivered to a GameMode.
+// Kind tells which client message it came from; Round and Choice are only
+// set for answers.
+//
+// ReceivedAt is when the server read the input. At is corrected for the
+// player's latency: subtracting the time a stimulus was sent from At gives
+// the player's reaction time. Modes should time responses with At.
+type PlayerInput struct {
+	ClientID   ClientID
+	Kind       ClientMessageType
+	Action     string
+	Round      int
+	Choice     int
+	ClientTime int64
+	ReceivedAt time.Time
+	At         time.Time
+}
+
+// GameModeFactory creates the GameMode for a new Game.
+// The settings have already been validated.
+type GameModeFactory func(settings GameSettings) GameMode
+
+// PlayerLimits is the number of players a GameMode can be played with.
+type PlayerLimits struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// AnyPlayerCount lets a GameMode be played by any party the server allows.
+var AnyPlayerCount = PlayerLimits{Min: minPartySize, Max: maxPartySizeLimit}
+
+// gameModeEntry is a registered GameMode.
+type gameModeEntry struct {
+	factory GameModeFactory
+	players PlayerLimits
+}
+
+// gameModes holds every registered GameMode by name.
+// It is only written during package initialization.
+var gameModes = make(map[string]gameModeEntry)
+
+// RegisterGameMode makes a GameMode available by name, for games of the
+// given number of players. It is meant to be called from init and panics
+// if the name is already taken or the limits are outside the server's.
+func RegisterGameMode(name string, players PlayerLimits, factory GameModeFactory) {
+	if _, exists := gameModes[name]; exists {
+		panic("game mode already registered: " + name)
+	}
+	if players.Min < minPartySize || players.Max > maxPartySizeLimit || players.Min > players.Max {
+		panic(fmt.Sprintf("game mode %s: invalid player limits %+v", name, players))
+	}
+	gameModes[name] = gameModeEntry{factory: factory, players: players}
+}
+
+// NewGameMode creates the GameMode named in the settings.
+func NewGameMode(settings GameSettings) (GameMode, error) {
+	entry, ok := gameModes[settings.Mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown game mode %q", settings.Mode)
+	}
+	return entry.factory(settings), nil
+}
+
+// gameModePlayers returns the player limits of the named GameMode, or
+// AnyPlayerCount if it is unknown.
+func gameModePlayers(name string) PlayerLimits {
+	if entry, ok := gameModes[name]; ok {
+		return entry.players
+	}
+	return AnyPlayerCount
+}
@@ -0,0 +1,762 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------
+
+// fastGameSettings returns settings short enough to play rounds in tests.
+func fastGameSettings() GameSettings {
+	return GameSettings{
+		Mode:                GameModeClassic,
+		RoundTypes:          []RoundType{RoundTypeReaction},
+		CountdownSeconds:    0,
+		MinStimulusDelayMs:  50,
+		MaxStimulusDelayMs:  50,
+		FalseStartPenaltyMs: 500,
+		RoundTimeLimitMs:    300,
+		IntermissionMs:      50,
+	}
+}
+
+// newTestGame creates a running Game with n connectionless Clients whose
+// outbound messages can be read straight from their send channels.
+func newTestGame(t *testing.T, n int, settings GameSettings) (*Game, []*Client) {
+	t.Helper()
+	pm := NewPartyManagerWithTimeouts(100*time.Millisecond, 50*time.Millisecond)
+	p := NewParty(NewPartyID())
+
+	clients := make([]*Client, 0, n)
+	clientsMap := make(map[ClientID]*Client)
+	for range n {
+		c := &Client{ID: NewClientID(), send: make(chan ServerMessage, 64), pm: pm}
+		p.AddClient(c)
+		clients = append(clients, c)
+		clientsMap[c.ID] = c
+	}
+
+	mode, err := NewGameMode(settings)
+	if err != nil {
+		t.Fatalf("failed to create game mode: %v", err)
+	}
+	g := NewGame(pm, p, clientsMap, mode, settings)
+	p.game = g
+	g.Start()
+	t.Cleanup(func() { g.SendCommand(GameCommand{Type: GameCommandEndGame}) })
+	return g, clients
+}
+
+// expectSent drains a Client's send channel until it finds the target type
+// and decodes the payload into out.
+func expectSent(t *testing.T, c *Client, target ServerMessageType, out any) {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg := <-c.send:
+			if msg.Type != target {
+				continue
+			}
+			if out != nil {
+				if err := json.Unmarshal(msg.Payload, out); err != nil {
+					t.Fatalf("invalid %s payload: %v", target, err)
+				}
+			}
+			return
+		case <-deadline:
+			t.Fatalf("timed out waiting for %s", target)
+		}
+	}
+}
+
+// press sends a player action for the given Client.
+func press(g *Game, c *Client) {
+	g.SendCommand(GameCommand{
+		Type: GameCommandPlayerAction,
+		Payload: GameCommandPlayerActionPayload{
+			ClientID:   c.ID,
+			Action:     "react",
+			ReceivedAt: time.Now(),
+		},
+	})
+}
+
+// resultFor finds the given player's result in a round result payload.
+func resultFor(t *testing.T, pl ServerMessageRoundResultPayload, cid ClientID) RoundPlayerResult {
+	t.Helper()
+	for _, r := range pl.Results {
+		if r.PlayerID == cid {
+			return r
+		}
+	}
+	t.Fatalf("no result for player %s", cid)
+	return RoundPlayerResult{}
+}
+
+// ---------------------------------------------------------------------
+// Round Engine Tests
+// ---------------------------------------------------------------------
+
+// TestReactionRound verifies the round flow:
+//
+//	gameStarted -> roundStarted -> stimulus -> roundResult
+func TestReactionRound(t *testing.T) {
+	g, clients := newTestGame(t, 2, fastGameSettings())
+	a, b := clients[0], clients[1]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	var started ServerMessageRoundStartedPayload
+	expectSent(t, a, ServerMessageRoundStarted, &started)
+	if started.Round != 1 || started.RoundType != RoundTypeReaction {
+		t.Fatalf("unexpected roundStarted payload: %+v", started)
+	}
+
+	expectSent(t, a, ServerMessageStimulus, nil)
+	press(g, a)
+	time.Sleep(20 * time.Millisecond)
+	press(g, b)
+
+	var result ServerMessageRoundResultPayload
+	expectSent(t, b, ServerMessageRoundResult, &result)
+	ra, rb := resultFor(t, result, a.ID), resultFor(t, result, b.ID)
+	if ra.Outcome != RoundOutcomeHit || rb.Outcome != RoundOutcomeHit {
+		t.Fatalf("expected both hits, got %s and %s", ra.Outcome, rb.Outcome)
+	}
+	if ra.Rank != 1 || rb.Rank != 2 {
+		t.Fatalf("expected A to rank 1 and B to rank 2, got %d and %d", ra.Rank, rb.Rank)
+	}
+}
+
+// TestReactionRoundFalseStart verifies that pressing before the stimulus
+// is penalized and a silent player is marked as missed.
+func TestReactionRoundFalseStart(t *testing.T) {
+	settings := fastGameSettings()
+	g, clients := newTestGame(t, 2, settings)
+	a, b := clients[0], clients[1]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	expectSent(t, a, ServerMessageRoundStarted, nil)
+	press(g, a)
+
+	var result ServerMessageRoundResultPayload
+	expectSent(t, a, ServerMessageRoundResult, &result)
+	ra, rb := resultFor(t, result, a.ID), resultFor(t, result, b.ID)
+	if ra.Outcome != RoundOutcomeFalseStart {
+		t.Fatalf("expected false start, got %s", ra.Outcome)
+	}
+	if ra.TimeMs != int64(settings.RoundTimeLimitMs+settings.FalseStartPenaltyMs) {
+		t.Fatalf("expected false start penalty to be applied, got %dms", ra.TimeMs)
+	}
+	if rb.Outcome != RoundOutcomeMissed || rb.Rank != 1 {
+		t.Fatalf("expected B to miss and rank ahead of A, got %s rank %d", rb.Outcome, rb.Rank)
+	}
+}
+
+// TestReactionRoundsContinue verifies a new round starts after the results.
+func TestReactionRoundsContinue(t *testing.T) {
+	g, clients := newTestGame(t, 2, fastGameSettings())
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	expectSent(t, clients[0], ServerMessageRoundResult, nil)
+
+	var started ServerMessageRoundStartedPayload
+	expectSent(t, clients[0], ServerMessageRoundStarted, &started)
+	if started.Round != 2 {
+		t.Fatalf("expected round 2, got %d", started.Round)
+	}
+}
+
+// solvePuzzle finds the correct cell of a puzzle as a player would.
+func solvePuzzle(t *testing.T, p Puzzle) int {
+	t.Helper()
+	counts := make(map[string]int)
+	for _, cell := range p.Cells {
+		counts[cell]++
+	}
+	for i, cell := range p.Cells {
+		switch p.Kind {
+		case PuzzleKindOddOneOut:
+			if counts[cell] == 1 {
+				return i
+			}
+		case PuzzleKindMatchTarget:
+			if cell == p.Target {
+				return i
+			}
+		}
+	}
+	t.Fatalf("puzzle has no solution: %+v", p)
+	return -1
+}
+
+// answer submits a puzzle answer for the given Client.
+func answer(g *Game, c *Client, round, choice int) {
+	g.SendCommand(GameCommand{
+		Type: GameCommandSubmitAnswer,
+		Payload: GameCommandSubmitAnswerPayload{
+			ClientID:   c.ID,
+			Round:      round,
+			Choice:     choice,
+			ReceivedAt: time.Now(),
+		},
+	})
+}
+
+// TestPatternRound verifies that a pattern round sends a puzzle and
+// scores correct answers ahead of wrong ones.
+func TestPatternRound(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundTypes = []RoundType{RoundTypePattern}
+	g, clients := newTestGame(t, 2, settings)
+	a, b := clients[0], clients[1]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	var started ServerMessageRoundStartedPayload
+	expectSent(t, a, ServerMessageRoundStarted, &started)
+	if started.RoundType != RoundTypePattern {
+		t.Fatalf("expected pattern round, got %s", started.RoundType)
+	}
+
+	var pl ServerMessagePuzzlePayload
+	expectSent(t, a, ServerMessagePuzzle, &pl)
+	if len(pl.Puzzle.Cells) != pl.Puzzle.Rows*pl.Puzzle.Cols {
+		t.Fatalf("puzzle grid has %d cells, expected %dx%d", len(pl.Puzzle.Cells), pl.Puzzle.Rows, pl.Puzzle.Cols)
+	}
+
+	correct := solvePuzzle(t, pl.Puzzle)
+	answer(g, b, pl.Round, (correct+1)%len(pl.Puzzle.Cells))
+	answer(g, a, pl.Round, correct)
+
+	var result ServerMessageRoundResultPayload
+	expectSent(t, a, ServerMessageRoundResult, &result)
+	ra, rb := resultFor(t, result, a.ID), resultFor(t, result, b.ID)
+	if ra.Outcome != RoundOutcomeHit || ra.Rank != 1 {
+		t.Fatalf("expected A to answer correctly and rank 1, got %s rank %d", ra.Outcome, ra.Rank)
+	}
+	if rb.Outcome != RoundOutcomeWrong || rb.Rank != 2 {
+		t.Fatalf("expected B to answer wrong and rank 2, got %s rank %d", rb.Outcome, rb.Rank)
+	}
+}
+
+// TestMixedRoundTypes verifies round types are played in configured order.
+func TestMixedRoundTypes(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundTypes = []RoundType{RoundTypeReaction, RoundTypePattern}
+	g, clients := newTestGame(t, 2, settings)
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	for i, want := range []RoundType{RoundTypeReaction, RoundTypePattern, RoundTypeReaction} {
+		var started ServerMessageRoundStartedPayload
+		expectSent(t, clients[0], ServerMessageRoundStarted, &started)
+		if started.RoundType != want {
+			t.Fatalf("round %d: expected %s, got %s", i+1, want, started.RoundType)
+		}
+	}
+}
+
+// TestGameCompletesAfterRoundCount verifies the Game ends on its own once
+// the round count is reached and names the top scorer as the winner.
+func TestGameCompletesAfterRoundCount(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundCount = 1
+	g, clients := newTestGame(t, 2, settings)
+	a, b := clients[0], clients[1]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	expectSent(t, a, ServerMessageStimulus, nil)
+	press(g, b)
+
+	var standings ServerMessageStandingsPayload
+	expectSent(t, a, ServerMessageStandings, &standings)
+	if len(standings.Standings) != 2 || standings.Standings[0].PlayerID != b.ID {
+		t.Fatalf("expected B to lead the standings, got %+v", standings.Standings)
+	}
+
+	var over ServerMessageGameEndedPayload
+	expectSent(t, a, ServerMessageGameOver, &over)
+	if over.Reason != GameEndReasonCompleted {
+		t.Fatalf("expected reason %s, got %s", GameEndReasonCompleted, over.Reason)
+	}
+	if over.WinnerID != b.ID {
+		t.Fatalf("expected B to win, got %q", over.WinnerID)
+	}
+	if len(over.Ranking) != 2 || over.Ranking[0].Score <= over.Ranking[1].Score {
+		t.Fatalf("expected ranking with scores, got %+v", over.Ranking)
+	}
+}
+
+// TestGameTiedWinners verifies that every player sharing first place is
+// reported as a winner.
+func TestGameTiedWinners(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundCount = 1
+	g, clients := newTestGame(t, 2, settings)
+	a := clients[0]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	// Both players miss the round
+	var over ServerMessageGameEndedPayload
+	expectSent(t, a, ServerMessageGameOver, &over)
+	if len(over.Ranking) != 2 || over.Ranking[0].Rank != 1 || over.Ranking[1].Rank != 1 {
+		t.Fatalf("expected both players to rank first, got %+v", over.Ranking)
+	}
+	if len(over.WinnerIDs) != 2 || over.WinnerID != over.Ranking[0].PlayerID {
+		t.Fatalf("expected both players to win, got %+v", over)
+	}
+}
+
+// TestGameCompletesAtTargetScore verifies the Game ends once a player
+// reaches the target score.
+func TestGameCompletesAtTargetScore(t *testing.T) {
+	settings := fastGameSettings()
+	settings.TargetScore = 1
+	g, clients := newTestGame(t, 2, settings)
+	a := clients[0]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	expectSent(t, a, ServerMessageStimulus, nil)
+	press(g, a)
+
+	var over ServerMessageGameEndedPayload
+	expectSent(t, a, ServerMessageGameOver, &over)
+	if over.Reason != GameEndReasonCompleted || over.WinnerID != a.ID {
+		t.Fatalf("expected A to win a completed game, got %+v", over)
+	}
+}
+
+// TestGameEndsWithNotEnoughPlayers verifies the end reason when a player
+// permanently leaves a two player Game.
+func TestGameEndsWithNotEnoughPlayers(t *testing.T) {
+	g, clients := newTestGame(t, 2, fastGameSettings())
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+	g.SendCommand(GameCommand{
+		Type:    GameCommandClientDisconnect,
+		Payload: GameCommandClientDisconnectPayload{ClientID: clients[1].ID},
+	})
+
+	var over ServerMessageGameEndedPayload
+	expectSent(t, clients[0], ServerMessageGameOver, &over)
+	if over.Reason != GameEndReasonNotEnoughPlayers {
+		t.Fatalf("expected reason %s, got %s", GameEndReasonNotEnoughPlayers, over.Reason)
+	}
+}
+
+// TestGameEndsBelowPartyMinSize verifies that a Game ends once fewer
+// players are left than its party's minimum size.
+func TestGameEndsBelowPartyMinSize(t *testing.T) {
+	settings := fastGameSettings()
+	pm := NewPartyManagerWithTimeouts(100*time.Millisecond, 50*time.Millisecond)
+	p := NewParty(NewPartyID())
+	p.MinSize = 3
+	clients := make([]*Client, 0, 3)
+	clientsMap := make(map[ClientID]*Client)
+	for range 3 {
+		c := &Client{ID: NewClientID(), send: make(chan ServerMessage, 64), pm: pm}
+		p.AddClient(c)
+		clients = append(clients, c)
+		clientsMap[c.ID] = c
+	}
+	mode, _ := NewGameMode(settings)
+	g := NewGame(pm, p, clientsMap, mode, settings)
+	g.Start()
+	t.Cleanup(func() { g.SendCommand(GameCommand{Type: GameCommandEndGame}) })
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	g.SendCommand(GameCommand{
+		Type:    GameCommandClientDisconnect,
+		Payload: GameCommandClientDisconnectPayload{ClientID: clients[2].ID},
+	})
+	var over ServerMessageGameEndedPayload
+	expectSent(t, clients[0], ServerMessageGameOver, &over)
+	if over.Reason != GameEndReasonNotEnoughPlayers {
+		t.Fatalf("expected reason %s, got %s", GameEndReasonNotEnoughPlayers, over.Reason)
+	}
+}
+
+// firstInputMode is a minimal GameMode that ends the Game as soon as any
+// player sends an input, used to check that Game only drives the hooks.
+type firstInputMode struct {
+	left chan ClientID
+}
+
+func (m *firstInputMode) Start(*Game)              {}
+func (m *firstInputMode) Timer(*Game, string)      {}
+func (m *firstInputMode) End(*Game, GameEndReason) {}
+
+func (m *firstInputMode) PlayerInput(g *Game, in PlayerInput) {
+	g.finish(GameEndReasonCompleted)
+}
+
+func (m *firstInputMode) PlayerLeft(_ *Game, cid ClientID) {
+	m.left <- cid
+}
+
+// TestCustomGameMode verifies that a registered GameMode receives the
+// Game's hooks without any changes to Game itself.
+func TestCustomGameMode(t *testing.T) {
+	mode := &firstInputMode{left: make(chan ClientID, 1)}
+	RegisterGameMode("firstInput", AnyPlayerCount, func(GameSettings) GameMode { return mode })
+	t.Cleanup(func() { delete(gameModes, "firstInput") })
+
+	settings := fastGameSettings()
+	settings.Mode = "firstInput"
+	g, clients := newTestGame(t, 3, settings)
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	g.SendCommand(GameCommand{
+		Type:    GameCommandClientDisconnect,
+		Payload: GameCommandClientDisconnectPayload{ClientID: clients[2].ID},
+	})
+	select {
+	case cid := <-mode.left:
+		if cid != clients[2].ID {
+			t.Fatalf("expected %s to leave, got %s", clients[2].ID, cid)
+		}
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting for PlayerLeft hook")
+	}
+
+	press(g, clients[0])
+	var over ServerMessageGameEndedPayload
+	expectSent(t, clients[1], ServerMessageGameOver, &over)
+	if over.Reason != GameEndReasonCompleted {
+		t.Fatalf("expected reason %s, got %s", GameEndReasonCompleted, over.Reason)
+	}
+}
+
+// TestGameMessagesNamePlayers verifies that game messages carry the
+// players' display names next to their IDs.
+func TestGameMessagesNamePlayers(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundCount = 1
+	pm := NewPartyManagerWithTimeouts(100*time.Millisecond, 50*time.Millisecond)
+	a := &Client{ID: NewClientID(), send: make(chan ServerMessage, 64), pm: pm, profile: PlayerProfile{DisplayName: "Alice"}}
+	b := &Client{ID: NewClientID(), send: make(chan ServerMessage, 64), pm: pm, profile: PlayerProfile{DisplayName: "Bob"}}
+	mode, _ := NewGameMode(settings)
+	g := NewGame(pm, NewParty(NewPartyID()), map[ClientID]*Client{a.ID: a, b.ID: b}, mode, settings)
+	g.Start()
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	var started ServerMessageGameStartedPayload
+	expectSent(t, a, ServerMessageGameStarted, &started)
+	if len(started.Players) != 2 {
+		t.Fatalf("expected 2 players in gameStarted, got %+v", started.Players)
+	}
+	for _, p := range started.Players {
+		if want := map[ClientID]string{a.ID: "Alice", b.ID: "Bob"}[p.PlayerID]; p.DisplayName != want {
+			t.Fatalf("expected %s to be named %q, got %q", p.PlayerID, want, p.DisplayName)
+		}
+	}
+
+	expectSent(t, a, ServerMessageStimulus, nil)
+	press(g, b)
+
+	var result ServerMessageRoundResultPayload
+	expectSent(t, a, ServerMessageRoundResult, &result)
+	if rb := resultFor(t, result, b.ID); rb.DisplayName != "Bob" {
+		t.Fatalf("expected round result to name Bob, got %+v", rb)
+	}
+
+	var over ServerMessageGameEndedPayload
+	expectSent(t, a, ServerMessageGameOver, &over)
+	if over.WinnerName != "Bob" || over.Ranking[1].DisplayName != "Alice" {
+		t.Fatalf("expected gameOver to name players, got %+v", over)
+	}
+}
+
+// ---------------------------------------------------------------------
+// Clock Sync Tests
+// ---------------------------------------------------------------------
+
+// syncClock gives the Client a clock estimate with the given RTT and offset.
+func syncClock(c *Client, rtt, offset time.Duration) {
+	now := time.Now()
+	c.clock = newClockSync()
+	ping := c.clock.newPing(now.Add(-rtt))
+	clientTime := now.Add(-rtt / 2).Add(offset)
+	c.clock.addSample(ClientMessageClockPongPayload{ID: ping.ID, ClientTime: clientTime.UnixMilli()}, now)
+}
+
+// TestClockEstimateUsesLowestRTT verifies that the sample least affected
+// by queuing is trusted.
+func TestClockEstimateUsesLowestRTT(t *testing.T) {
+	cs := newClockSync()
+	now := time.Now()
+	for _, rtt := range []time.Duration{300, 80, 200} {
+		rtt *= time.Millisecond
+		ping := cs.newPing(now.Add(-rtt))
+		clientTime := now.Add(-rtt / 2).Add(time.Second)
+		if _, ok := cs.addSample(ClientMessageClockPongPayload{ID: ping.ID, ClientTime: clientTime.UnixMilli()}, now); !ok {
+			t.Fatalf("sample for ping %d rejected", ping.ID)
+		}
+	}
+
+	est, ok := cs.estimate()
+	if !ok {
+		t.Fatal("expected an estimate")
+	}
+	if est.RTT != 80*time.Millisecond {
+		t.Fatalf("expected RTT of 80ms, got %v", est.RTT)
+	}
+	if d := est.Offset - time.Second; d < -time.Millisecond || d > time.Millisecond {
+		t.Fatalf("expected offset of 1s, got %v", est.Offset)
+	}
+
+	if _, ok := cs.addSample(ClientMessageClockPongPayload{ID: 99}, now); ok {
+		t.Fatal("expected pong for unknown ping to be rejected")
+	}
+}
+
+// TestCorrectInputTime verifies the latency correction with and without a
+// client timestamp, and that client timestamps cannot claim more time than
+// the measured RTT allows.
+func TestCorrectInputTime(t *testing.T) {
+	received := time.UnixMilli(1_000_000)
+	est := ClockEstimate{Offset: 5 * time.Second, RTT: 100 * time.Millisecond}
+	clientAt := func(d time.Duration) int64 {
+		return received.Add(d).Add(est.Offset).UnixMilli()
+	}
+
+	tests := []struct {
+		name       string
+		clientTime int64
+		want       time.Duration
+	}{
+		{"no client time", 0, -100 * time.Millisecond},
+		{"client time within window", clientAt(-30 * time.Millisecond), -80 * time.Millisecond},
+		{"client time too early", clientAt(-time.Second), -150 * time.Millisecond},
+		{"client time in the future", clientAt(time.Second), -50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		got := correctInputTime(received, tt.clientTime, est).Sub(received)
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	// Huge RTTs are capped
+	slow := ClockEstimate{RTT: 10 * time.Second}
+	if got := correctInputTime(received, 0, slow).Sub(received); got != -maxLatencyCorrection {
+		t.Errorf("expected correction capped at %v, got %v", -maxLatencyCorrection, got)
+	}
+}
+
+// TestReactionRoundLatencyCorrected verifies that a player on a slow
+// connection is not ranked behind a faster connection for the same
+// reaction.
+func TestReactionRoundLatencyCorrected(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundTimeLimitMs = 1000
+	g, clients := newTestGame(t, 2, settings)
+	a, b := clients[0], clients[1]
+	syncClock(a, 150*time.Millisecond, 0)
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	expectSent(t, a, ServerMessageStimulus, nil)
+	time.Sleep(150 * time.Millisecond)
+	press(g, b)
+	time.Sleep(100 * time.Millisecond)
+	press(g, a)
+
+	var result ServerMessageRoundResultPayload
+	expectSent(t, b, ServerMessageRoundResult, &result)
+	ra, rb := resultFor(t, result, a.ID), resultFor(t, result, b.ID)
+	if ra.Rank != 1 || rb.Rank != 2 {
+		t.Fatalf("expected slow A to rank 1 after correction, got A=%+v B=%+v", ra, rb)
+	}
+}
+
+// ---------------------------------------------------------------------
+// Anti-Cheat Tests
+// ---------------------------------------------------------------------
+
+// TestInhumanReactionRejected verifies that a press faster than the
+// minimum reaction time does not count and flags the player.
+func TestInhumanReactionRejected(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundCount = 1
+	settings.MinReactionMs = 100
+	settings.RoundTimeLimitMs = 1000
+	g, clients := newTestGame(t, 2, settings)
+	a, b := clients[0], clients[1]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	expectSent(t, a, ServerMessageStimulus, nil)
+	press(g, a)
+	time.Sleep(150 * time.Millisecond)
+	press(g, b)
+
+	var result ServerMessageRoundResultPayload
+	expectSent(t, a, ServerMessageRoundResult, &result)
+	if ra := resultFor(t, result, a.ID); ra.Outcome != RoundOutcomeMissed {
+		t.Fatalf("expected A's press to be rejected, got %+v", ra)
+	}
+
+	var over ServerMessageGameEndedPayload
+	expectSent(t, a, ServerMessageGameOver, &over)
+	if over.WinnerID != b.ID {
+		t.Fatalf("expected B to win, got %q", over.WinnerID)
+	}
+	last := over.Ranking[1]
+	if last.PlayerID != a.ID || len(last.Flags) != 1 || last.Flags[0] != CheatReasonInhumanReaction {
+		t.Fatalf("expected A to be flagged, got %+v", last)
+	}
+	if last.Disqualified {
+		t.Fatal("A should not be disqualified unless the settings say so")
+	}
+}
+
+// TestCheaterDisqualified verifies that a disqualified player cannot win,
+// even with the most points.
+func TestCheaterDisqualified(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundTypes = []RoundType{RoundTypePattern}
+	settings.RoundCount = 2
+	settings.DisqualifyCheaters = true
+	g, clients := newTestGame(t, 2, settings)
+	a, b := clients[0], clients[1]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	// A scores the first round, then answers a puzzle it was never shown
+	var puzzle ServerMessagePuzzlePayload
+	expectSent(t, a, ServerMessagePuzzle, &puzzle)
+	answer(g, a, 1, solvePuzzle(t, puzzle.Puzzle))
+	expectSent(t, a, ServerMessageRoundResult, nil)
+	answer(g, a, 2, 0)
+
+	var over ServerMessageGameEndedPayload
+	expectSent(t, b, ServerMessageGameOver, &over)
+	if over.WinnerID != b.ID {
+		t.Fatalf("expected B to win, got %q", over.WinnerID)
+	}
+	last := over.Ranking[1]
+	if last.PlayerID != a.ID || !last.Disqualified || last.Flags[0] != CheatReasonUnseenPuzzle {
+		t.Fatalf("expected A to be disqualified for an unseen puzzle, got %+v", last)
+	}
+	if last.Score <= over.Ranking[0].Score {
+		t.Fatalf("expected A to keep the higher score, got %+v", over.Ranking)
+	}
+}
+
+// TestRoundSkipsDisqualifiedPlayers verifies that a round ends as soon as
+// every player still in contention has responded.
+func TestRoundSkipsDisqualifiedPlayers(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundCount = 1
+	settings.MinReactionMs = 100
+	settings.RoundTimeLimitMs = 5000
+	settings.DisqualifyCheaters = true
+	g, clients := newTestGame(t, 3, settings)
+	a, b, c := clients[0], clients[1], clients[2]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	expectSent(t, a, ServerMessageStimulus, nil)
+	shownAt := time.Now()
+	press(g, a)
+	time.Sleep(150 * time.Millisecond)
+	press(g, b)
+	press(g, c)
+
+	var result ServerMessageRoundResultPayload
+	expectSent(t, b, ServerMessageRoundResult, &result)
+	if waited := time.Since(shownAt); waited >= time.Duration(settings.RoundTimeLimitMs)*time.Millisecond {
+		t.Fatalf("expected the round to end before the time limit, took %v", waited)
+	}
+	if ra := resultFor(t, result, a.ID); ra.Outcome != RoundOutcomeMissed {
+		t.Fatalf("expected A's press to be rejected, got %+v", ra)
+	}
+}
+
+// TestAntiCheatChecks exercises the checks that need precise timing.
+func TestAntiCheatChecks(t *testing.T) {
+	stimulusAt := time.Now()
+	est := ClockEstimate{Offset: time.Minute, RTT: 40 * time.Millisecond}
+	newGuard := func() *antiCheat {
+		ac := newAntiCheat(GameSettings{MinReactionMs: 100})
+		ac.markStimulus(1, RoundTypeReaction, stimulusAt)
+		return ac
+	}
+	pressAt := func(d time.Duration) PlayerInput {
+		return PlayerInput{
+			ClientID:   "a",
+			Kind:       ClientMessagePlayerAction,
+			ClientTime: stimulusAt.Add(d).Add(est.Offset).UnixMilli(),
+			ReceivedAt: stimulusAt.Add(d + est.RTT/2),
+			At:         stimulusAt.Add(d),
+		}
+	}
+
+	forged := pressAt(200 * time.Millisecond)
+	forged.ClientTime = stimulusAt.Add(-200 * time.Millisecond).Add(est.Offset).UnixMilli()
+
+	tests := []struct {
+		name string
+		in   PlayerInput
+		want CheatReason
+	}{
+		{"human reaction", pressAt(200 * time.Millisecond), ""},
+		{"false start", pressAt(-200 * time.Millisecond), ""},
+		{"inhuman reaction", pressAt(30 * time.Millisecond), CheatReasonInhumanReaction},
+		{"timestamped before stimulus", forged, CheatReasonBeforeStimulus},
+	}
+	for _, tt := range tests {
+		reason, _, _ := newGuard().check(tt.in, est, true)
+		if reason != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, reason)
+		}
+	}
+
+	ac := newGuard()
+	var reason CheatReason
+	for i := range maxInputsPerBurst + 1 {
+		reason, _, _ = ac.check(pressAt(time.Second+time.Duration(i)*time.Millisecond), est, true)
+	}
+	if reason != CheatReasonBurst {
+		t.Fatalf("expected burst to be detected, got %q", reason)
+	}
+}
+
+// TestGameSnapshot verifies that a snapshot describes the round in
+// progress, and that none is taken once the Game ended.
+func TestGameSnapshot(t *testing.T) {
+	settings := fastGameSettings()
+	settings.RoundTimeLimitMs = 2000
+	g, clients := newTestGame(t, 2, settings)
+	a := clients[0]
+	g.SendCommand(GameCommand{Type: GameCommandStartGame})
+	expectSent(t, a, ServerMessageStimulus, nil)
+	press(g, a)
+
+	snaps := make(chan *GameSnapshot, 1)
+	if !g.Snapshot(func(s *GameSnapshot) { snaps <- s }) {
+		t.Fatal("expected a snapshot of the running game")
+	}
+	snap := <-snaps
+	if snap == nil {
+		t.Fatal("expected the running game to be described")
+	}
+	r := snap.Round
+	if r == nil || r.Phase != string(roundPhaseLive) || r.Round != 1 || r.StimulusAt == 0 {
+		t.Fatalf("expected the first round to be live, got %+v", r)
+	}
+	if r.Deadline <= snap.Timestamp {
+		t.Fatalf("expected the round to end after %d, got %d", snap.Timestamp, r.Deadline)
+	}
+	if len(r.Responded) != 1 || r.Responded[0] != a.ID {
+		t.Fatalf("expected only A to have responded, got %v", r.Responded)
+	}
+	if len(snap.Players) != 2 || len(snap.Standings) != 2 {
+		t.Fatalf("expected both players, got %+v", snap)
+	}
+
+	g.SendCommand(GameCommand{Type: GameCommandEndGame})
+	<-g.done
+	if g.Snapshot(func(*GameSnapshot) { t.Error("unexpected snapshot of an ended game") }) {
+		t.Fatal("expected no snapshot once the game ended")
+	}
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Weight of the latest websocket ping in a client's round trip time.
+	rttSmoothing = 0.25
+
+	maxRegionLength = 32
+)
+
+// latency tracks a client's round trip time, measured by the websocket
+// pings of writePump and the pongs readPump receives. It is shared by the
+// pumps and the PartyManager goroutine.
+type latency struct {
+	mu         sync.Mutex
+	rtt        time.Duration
+	measured   bool
+	measuredCh chan struct{} // closed on the first sample
+}
+
+// newLatency creates a latency without samples.
+func newLatency() *latency {
+	return &latency{measuredCh: make(chan struct{})}
+}
+
+// pingData returns the payload of a websocket ping sent at the given time.
+func (l *latency) pingData(sentAt time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(sentAt.UnixNano()))
+}
+
+// addPong adds a sample from the payload of a pong. Pongs that do not
+// answer one of our pings are ignored.
+func (l *latency) addPong(data []byte, receivedAt time.Time) {
+	if len(data) != 8 {
+		return
+	}
+	sentAt := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+	sample := receivedAt.Sub(sentAt)
+	if sample < 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.measured {
+		l.rtt, l.measured = sample, true
+		close(l.measuredCh)
+		return
+	}
+	l.rtt += time.Duration(rttSmoothing * float64(sample-l.rtt))
+}
+
+// get returns the smoothed round trip time, if any pong arrived.
+func (l *latency) get() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rtt, l.measured
+}
+
+// normalizeRegion lowercases a region hint and trims surrounding space.
+func normalizeRegion(region string) string {
+	return strings.ToLower(strings.TrimSpace(region))
+}
+
+// validateRegion checks a normalized region hint. Hints are free-form
+// names such as "eu-west", agreed on by clients.
+func validateRegion(region string) error {
+	if len(region) > maxRegionLength {
+		return fmt.Errorf("region must be at most %d characters", maxRegionLength)
+	}
+	for _, r := range region {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return fmt.Errorf("region may only hold letters, digits and dashes")
+		}
+	}
+	return nil
+}
+
+// latencyBucket describes where a group of players connects from: the
+// region they declared, if any, and the range of their measured round
+// trip times, if any were measured.
+type latencyBucket struct {
+	region   string
+	minRTT   time.Duration
+	maxRTT   time.Duration
+	measured bool
+}
+
+// clientBucket returns the bucket of a single client.
+func clientBucket(c *Client) latencyBucket {
+	b := latencyBucket{region: c.region}
+	b.minRTT, b.measured = c.RTT()
+	b.maxRTT = b.minRTT
+	return b
+}
+
+// partyBucket returns the bucket of a Party's members.
+func partyBucket(p *Party) latencyBucket {
+	var b latencyBucket
+	for _, m := range p.Members {
+		b, _ = b.join(clientBucket(m.Client))
+	}
+	return b
+}
+
+// join returns the bucket of both groups together. It reports false if
+// they declared different regions.
+func (b latencyBucket) join(o latencyBucket) (latencyBucket, bool) {
+	if b.region != "" && o.region != "" && b.region != o.region {
+		return b, false
+	}
+	j := b
+	if j.region == "" {
+		j.region = o.region
+	}
+	switch {
+	case !o.measured:
+	case !j.measured:
+		j.minRTT, j.maxRTT, j.measured = o.minRTT, o.maxRTT, true
+	default:
+		j.minRTT, j.maxRTT = min(j.minRTT, o.minRTT), max(j.maxRTT, o.maxRTT)
+	}
+	return j, true
+}
+
+// spread returns the difference between the highest and lowest round trip
+// time in the bucket.
+func (b latencyBucket) spread() time.Duration {
+	return b.maxRTT - b.minRTT
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// Public parties fill up to this many players.
+	matchTargetSize = maxPartySize
+
+	// A public party accepts players rated within matchInitialGap of its
+	// average rating. The gap widens by matchGapGrowth every second the
+	// party waits to fill, up to matchMaxGap.
+	matchInitialGap = 100
+	matchGapGrowth  = 25
+	matchMaxGap     = 800
+
+	// Players are only matched if their websocket round trip times are
+	// within matchMaxRTTSpread of each other.
+	matchMaxRTTSpread = 60 * time.Millisecond
+
+	// A public party that waited this long to fill starts its game with
+	// the players it has.
+	matchFillTimeout = 30 * time.Second
+
+	// How often waiting public parties are merged.
+	matchInterval = time.Second
+)
+
+// lobby is a public party the matchmaker is filling.
+type lobby struct {
+	party    *Party
+	openedAt time.Time
+}
+
+// Matchmaker groups queued players with similar ratings into public
+// parties of TargetSize.
+//
+// Each queued player joins the waiting public party whose average rating
+// is closest to theirs, provided it is within the party's allowed gap.
+// Otherwise they open a new one. The gap widens the longer a party waits,
+// and waiting parties whose gaps overlap are merged.
+//
+// Reaction games are unfair between players with very different
+// latencies, so players who declared different regions are never matched,
+// and the round trip times of a party's members may differ by at most
+// MaxRTTSpread. Players are only placed once their round trip time is
+// measured, unless it takes longer than queueRTTWait. Those without one
+// fit anywhere in their region.
+//
+// A public party starts its game once it reaches TargetSize, or once it
+// waited FillTimeout with enough players.
+//
+// It is owned by the PartyManager goroutine.
+type Matchmaker struct {
+	Ratings      *Ratings
+	TargetSize   int
+	InitialGap   float64
+	GapGrowth    float64 // per second waited
+	MaxGap       float64
+	MaxRTTSpread time.Duration
+	FillTimeout  time.Duration
+
+	lobbies []lobby // oldest first
+}
+
+// NewMatchmaker creates a Matchmaker with the default gaps.
+func NewMatchmaker(ratings *Ratings) *Matchmaker {
+	return &Matchmaker{
+		Ratings:      ratings,
+		TargetSize:   matchTargetSize,
+		InitialGap:   matchInitialGap,
+		GapGrowth:    matchGapGrowth,
+		MaxGap:       matchMaxGap,
+		MaxRTTSpread: matchMaxRTTSpread,
+		FillTimeout:  matchFillTimeout,
+	}
+}
+
+// open starts filling a new public party.
+func (mm *Matchmaker) open(p *Party, now time.Time) {
+	p.MaxSize = mm.TargetSize
+	mm.lobbies = append(mm.lobbies, lobby{party: p, openedAt: now})
+}
+
+// remove stops filling a public party.
+func (mm *Matchmaker) remove(p *Party) {
+	mm.lobbies = slices.DeleteFunc(mm.lobbies, func(l lobby) bool { return l.party == p })
+}
+
+// filled reports whether a public party the matchmaker is filling is full,
+// or waited long enough that it should start with the players it has.
+func (mm *Matchmaker) filled(p *Party, now time.Time) bool {
+	for _, l := range mm.lobbies {
+		if l.party == p {
+			return p.IsFull() || now.Sub(l.openedAt) >= mm.FillTimeout
+		}
+	}
+	return false
+}
+
+// lobbyFor returns the public party a queued client should join, or nil
+// if none fits.
+func (mm *Matchmaker) lobbyFor(c *Client, now time.Time) *Party {
+	mm.prune()
+	rating := mm.Ratings.Get(c.Player)
+	bucket := clientBucket(c)
+	var best *Party
+	bestDist := math.Inf(1)
+	for _, l := range mm.lobbies {
+		if l.party.IsFull() || !mm.fits(partyBucket(l.party), bucket) {
+			continue
+		}
+		dist := math.Abs(rating - mm.average(l.party))
+		if dist <= mm.gap(l, now) && dist < bestDist {
+			best, bestDist = l.party, dist
+		}
+	}
+	return best
+}
+
+// merges returns pairs of waiting public parties that should be merged,
+// the first party of each pair taking in the members of the second.
+func (mm *Matchmaker) merges(now time.Time) [][2]*Party {
+	mm.prune()
+	var pairs [][2]*Party
+	merged := make(map[*Party]bool)
+	for i, into := range mm.lobbies {
+		if merged[into.party] || !mm.waiting(into.party) {
+			continue
+		}
+		for _, from := range mm.lobbies[i+1:] {
+			if merged[from.party] || !mm.waiting(from.party) {
+				continue
+			}
+			if len(into.party.Members)+len(from.party.Members) > mm.TargetSize ||
+				!mm.fits(partyBucket(into.party), partyBucket(from.party)) {
+				continue
+			}
+			dist := math.Abs(mm.average(into.party) - mm.average(from.party))
+			if dist <= max(mm.gap(into, now), mm.gap(from, now)) {
+				pairs = append(pairs, [2]*Party{into.party, from.party})
+				merged[into.party], merged[from.party] = true, true
+				break
+			}
+		}
+	}
+	return pairs
+}
+
+// fits reports whether players of both buckets may be matched.
+func (mm *Matchmaker) fits(a, b latencyBucket) bool {
+	j, ok := a.join(b)
+	return ok && (!j.measured || j.spread() <= mm.MaxRTTSpread)
+}
+
+// prune stops filling public parties that started a game.
+func (mm *Matchmaker) prune() {
+	mm.lobbies = slices.DeleteFunc(mm.lobbies, func(l lobby) bool { return l.party.game != nil })
+}
+
+// waiting reports whether a public party may be merged into another.
+func (mm *Matchmaker) waiting(p *Party) bool {
+	return p.game == nil && p.readyCheck == nil && p.autoStart == nil && !p.IsEmpty()
+}
+
+// gap returns the rating gap a public party accepts after waiting since
+// it opened.
+func (mm *Matchmaker) gap(l lobby, now time.Time) float64 {
+	return min(mm.InitialGap+mm.GapGrowth*now.Sub(l.openedAt).Seconds(), mm.MaxGap)
+}
+
+// average returns the average rating of a party's members.
+func (mm *Matchmaker) average(p *Party) float64 {
+	if len(p.Members) == 0 {
+		return defaultRating
+	}
+	sum := 0.0
+	for _, m := range p.Members {
+		sum += mm.Ratings.Get(m.Client.Player)
+	}
+	return sum / float64(len(p.Members))
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRatingsUpdate verifies that ratings move by the Elo expectation of
+// each pair of players.
+func TestRatingsUpdate(t *testing.T) {
+	r := NewRatings()
+	a, b, c := NewClientID(), NewClientID(), NewClientID()
+	keys := map[ClientID]PlayerKey{a: NewPlayerKey(), b: NewPlayerKey(), c: NewPlayerKey()}
+	now := time.Now()
+
+	changes := r.Update([]PlayerStanding{{PlayerID: a, Rank: 1}, {PlayerID: b, Rank: 2}}, keys, now)
+	if changes[a] != ratingK/2 || changes[b] != -ratingK/2 {
+		t.Fatalf("expected even players to move by %d, got %v", ratingK/2, changes)
+	}
+	if r.Get(keys[a]) != defaultRating+ratingK/2 || r.Get(keys[c]) != defaultRating {
+		t.Fatalf("unexpected ratings %v and %v", r.Get(keys[a]), r.Get(keys[c]))
+	}
+
+	// Beating a stronger player is worth more than beating a weaker one
+	upset := r.Update([]PlayerStanding{{PlayerID: c, Rank: 1}, {PlayerID: a, Rank: 2}}, keys, now)
+	if upset[c] <= ratingK/2 {
+		t.Fatalf("expected an upset to be worth more than %d, got %v", ratingK/2, upset[c])
+	}
+
+	// Changes always balance out, however many played
+	sum := 0.0
+	for _, change := range r.Update([]PlayerStanding{{PlayerID: a, Rank: 1}, {PlayerID: b, Rank: 2}, {PlayerID: c, Rank: 3}}, keys, now) {
+		sum += change
+	}
+	if sum > 1e-9 || sum < -1e-9 {
+		t.Fatalf("expected changes to sum to 0, got %v", sum)
+	}
+}
+
+// TestRatingsExpire verifies that ratings are forgotten once they have
+// not changed for ratingTTL.
+func TestRatingsExpire(t *testing.T) {
+	r := NewRatings()
+	a, b := NewClientID(), NewClientID()
+	keys := map[ClientID]PlayerKey{a: NewPlayerKey(), b: NewPlayerKey()}
+	start := time.Now()
+	r.Update([]PlayerStanding{{PlayerID: a, Rank: 1}, {PlayerID: b, Rank: 2}}, keys, start)
+
+	r.Expire(start.Add(ratingTTL))
+	if r.Get(keys[a]) == defaultRating {
+		t.Fatal("expected a recent rating to be kept")
+	}
+	r.Expire(start.Add(ratingTTL + time.Second))
+	if r.Get(keys[a]) != defaultRating || len(r.ratings) != 0 {
+		t.Fatalf("expected stale ratings to be forgotten, got %v", r.ratings)
+	}
+}
+
+// ratedClient creates a client with the given rating.
+func ratedClient(r *Ratings, rating float64) *Client {
+	c := &Client{ID: NewClientID(), Player: NewPlayerKey(), rtt: newLatency()}
+	r.ratings[c.Player] = playerRating{rating: rating, ratedAt: time.Now()}
+	return c
+}
+
+// ratedParty creates a party whose members have the given ratings.
+func ratedParty(r *Ratings, ratings ...float64) *Party {
+	p := NewParty(NewPartyID())
+	for _, rating := range ratings {
+		p.AddClient(ratedClient(r, rating))
+	}
+	return p
+}
+
+// measuredClient creates a client with the given region and round trip
+// time.
+func measuredClient(r *Ratings, region string, rtt time.Duration) *Client {
+	c := ratedClient(r, defaultRating)
+	c.region = region
+	now := time.Now()
+	c.rtt.addPong(c.rtt.pingData(now.Add(-rtt)), now)
+	return c
+}
+
+// TestMatchmakerWidensGap verifies that a waiting public party accepts
+// players further from its rating the longer it waits.
+func TestMatchmakerWidensGap(t *testing.T) {
+	mm := NewMatchmaker(NewRatings())
+	start := time.Now()
+	p := ratedParty(mm.Ratings, 1200)
+	mm.open(p, start)
+
+	if got := mm.lobbyFor(ratedClient(mm.Ratings, 1250), start); got != p {
+		t.Fatal("expected a close rating to join the party")
+	}
+	if got := mm.lobbyFor(ratedClient(mm.Ratings, 1500), start); got != nil {
+		t.Fatal("expected a distant rating to open a new party")
+	}
+	if got := mm.lobbyFor(ratedClient(mm.Ratings, 1500), start.Add(10*time.Second)); got != p {
+		t.Fatal("expected the gap to widen after waiting")
+	}
+	if got := mm.lobbyFor(ratedClient(mm.Ratings, 2200), start.Add(time.Hour)); got != nil {
+		t.Fatal("expected the gap to stop widening")
+	}
+
+	// The closest party is preferred
+	closer := ratedParty(mm.Ratings, 1400)
+	mm.open(closer, start)
+	if got := mm.lobbyFor(ratedClient(mm.Ratings, 1390), start.Add(10*time.Second)); got != closer {
+		t.Fatal("expected the party closest in rating")
+	}
+}
+
+// TestMatchmakerMerges verifies that waiting public parties are merged
+// once their gaps overlap, without exceeding the target size.
+func TestMatchmakerMerges(t *testing.T) {
+	mm := NewMatchmaker(NewRatings())
+	start := time.Now()
+	low := ratedParty(mm.Ratings, 1200, 1200)
+	high := ratedParty(mm.Ratings, 1500)
+	full := ratedParty(mm.Ratings, 1200, 1200, 1200, 1200, 1200)
+	mm.open(low, start)
+	mm.open(high, start)
+	mm.open(full, start)
+
+	if pairs := mm.merges(start); len(pairs) != 0 {
+		t.Fatalf("expected no merges yet, got %d", len(pairs))
+	}
+	pairs := mm.merges(start.Add(10 * time.Second))
+	if len(pairs) != 1 || pairs[0][0] != low || pairs[0][1] != high {
+		t.Fatalf("expected the high party to merge into the low one, got %v", pairs)
+	}
+}
+
+// TestMatchmakerLatencyBuckets verifies that players are only matched
+// within their region and round trip time spread.
+func TestMatchmakerLatencyBuckets(t *testing.T) {
+	mm := NewMatchmaker(NewRatings())
+	now := time.Now()
+	p := NewParty(NewPartyID())
+	p.AddClient(measuredClient(mm.Ratings, "eu", 20*time.Millisecond))
+	mm.open(p, now)
+
+	if got := mm.lobbyFor(measuredClient(mm.Ratings, "eu", 60*time.Millisecond), now); got != p {
+		t.Fatal("expected a close round trip time to join the party")
+	}
+	if got := mm.lobbyFor(measuredClient(mm.Ratings, "eu", 250*time.Millisecond), now); got != nil {
+		t.Fatal("expected a distant round trip time to open a new party")
+	}
+	if got := mm.lobbyFor(measuredClient(mm.Ratings, "us", 20*time.Millisecond), now); got != nil {
+		t.Fatal("expected another region to open a new party")
+	}
+	if got := mm.lobbyFor(ratedClient(mm.Ratings, defaultRating), now); got != p {
+		t.Fatal("expected an unmeasured client without a region to join the party")
+	}
+
+	// Parties in different buckets are never merged
+	far := NewParty(NewPartyID())
+	far.AddClient(measuredClient(mm.Ratings, "eu", 250*time.Millisecond))
+	mm.open(far, now)
+	if pairs := mm.merges(now.Add(time.Hour)); len(pairs) != 0 {
+		t.Fatalf("expected no merges across buckets, got %d", len(pairs))
+	}
+}
+
+// TestWithMaxRTTSpread verifies that the option sets the spread of every
+// queue's Matchmaker.
+func TestWithMaxRTTSpread(t *testing.T) {
+	pm := NewPartyManagerWithTimeouts(100*time.Millisecond, 50*time.Millisecond, WithMaxRTTSpread(time.Second))
+	for name, q := range pm.Queues {
+		if q.Matchmaker.MaxRTTSpread != time.Second {
+			t.Errorf("queue %s: expected a spread of 1s, got %v", name, q.Matchmaker.MaxRTTSpread)
+		}
+	}
+}
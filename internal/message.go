@@ -12,12 +12,34 @@ type ClientMessageType string
 const (
 	ServerMessageConnectSuccess ServerMessageType = "connectSuccess"
 	ServerMessagePartyJoined    ServerMessageType = "partyJoined"
+	ServerMessagePartyCreated   ServerMessageType = "partyCreated"
 	ServerMessagePartyLeft      ServerMessageType = "partyLeft"
 	ServerMessageQueueJoined    ServerMessageType = "queueJoined"
+	ServerMessageQueueStatus    ServerMessageType = "queueStatus"
+	ServerMessageQueueLeft      ServerMessageType = "queueLeft"
+	ServerMessageQueueList      ServerMessageType = "queueList"
 	ServerMessageError          ServerMessageType = "error"
 	ServerMessageMemberUpdate   ServerMessageType = "memberUpdate"
 	ServerMessageGameOver       ServerMessageType = "gameOver"
 	ServerMessageGameStarted    ServerMessageType = "gameStarted"
+	ServerMessageRoundStarted   ServerMessageType = "roundStarted"
+	ServerMessageStimulus       ServerMessageType = "stimulus"
+	ServerMessagePuzzle         ServerMessageType = "puzzle"
+	ServerMessageRoundResult    ServerMessageType = "roundResult"
+	ServerMessageStandings      ServerMessageType = "standings"
+	ServerMessageClockPing      ServerMessageType = "clockPing"
+	ServerMessageClockSync      ServerMessageType = "clockSync"
+	ServerMessageReadyCheck     ServerMessageType = "readyCheck"
+	ServerMessageAutoStart      ServerMessageType = "autoStart"
+	ServerMessageProfileUpdated ServerMessageType = "profileUpdated"
+	ServerMessageChat           ServerMessageType = "chat"
+	ServerMessageRematch        ServerMessageType = "rematch"
+	ServerMessagePartySettings  ServerMessageType = "partySettings"
+	ServerMessagePartyList      ServerMessageType = "partyList"
+	ServerMessageRatingUpdate   ServerMessageType = "ratingUpdate"
+	ServerMessageReplay         ServerMessageType = "replay"
+	ServerMessageResync         ServerMessageType = "resync"
+	ServerMessageSnapshot       ServerMessageType = "snapshot"
 )
 
 const (
@@ -30,15 +52,44 @@ const (
 	ErrorCodeNotInGame        ServerErrorCode = "notInGame"
 	ErrorCodePartyFull        ServerErrorCode = "partyFull"
 	ErrorCodeQueueFull        ServerErrorCode = "queueFull"
+	ErrorCodeNotInQueue       ServerErrorCode = "notInQueue"
+	ErrorCodeQueueNotFound    ServerErrorCode = "queueNotFound"
 	ErrorCodeGameInProgress   ServerErrorCode = "gameInProgress"
 	ErrorCodeSessionExpired   ServerErrorCode = "expired"
+	ErrorCodeInvalidSettings  ServerErrorCode = "invalidSettings"
+	ErrorCodeMemberNotFound   ServerErrorCode = "memberNotFound"
+	ErrorCodeBanned           ServerErrorCode = "banned"
+	ErrorCodeInvalidProfile   ServerErrorCode = "invalidProfile"
+	ErrorCodeNameTaken        ServerErrorCode = "nameTaken"
+	ErrorCodeRateLimited      ServerErrorCode = "rateLimited"
+	ErrorCodeMessageRejected  ServerErrorCode = "messageRejected"
+	ErrorCodeNoRematch        ServerErrorCode = "noRematchVote"
+	ErrorCodeWrongPassword    ServerErrorCode = "wrongPassword"
+	ErrorCodePartyLocked      ServerErrorCode = "partyLocked"
+	ErrorCodeTooManyMembers   ServerErrorCode = "tooManyMembers"
 )
 
 const (
 	ClientMessageJoin         ClientMessageType = "join"
+	ClientMessageCreateParty  ClientMessageType = "createParty"
 	ClientMessageLeave        ClientMessageType = "leave"
+	ClientMessageCancelQueue  ClientMessageType = "cancelQueue"
+	ClientMessageListQueues   ClientMessageType = "listQueues"
+	ClientMessageKickMember   ClientMessageType = "kickMember"
+	ClientMessageBanMember    ClientMessageType = "banMember"
+	ClientMessageTransferHost ClientMessageType = "transferHost"
+	ClientMessageSetReady     ClientMessageType = "setReady"
+	ClientMessageSetProfile   ClientMessageType = "setProfile"
 	ClientMessageStartGame    ClientMessageType = "startGame"
+	ClientMessageEndGame      ClientMessageType = "endGame"
 	ClientMessagePlayerAction ClientMessageType = "playerAction"
+	ClientMessageSubmitAnswer ClientMessageType = "submitAnswer"
+	ClientMessageClockPong    ClientMessageType = "clockPong"
+	ClientMessageChat         ClientMessageType = "chat"
+	ClientMessageRematchVote  ClientMessageType = "rematchVote"
+	ClientMessageUpdateParty  ClientMessageType = "updatePartySettings"
+	ClientMessageListParties  ClientMessageType = "listParties"
+	ClientMessageSnapshot     ClientMessageType = "requestSnapshot"
 )
 
 // ---------------------------------------------------------------------
@@ -50,57 +101,331 @@ type ClientMessage struct {
 	Payload json.RawMessage   `json:"payload"`
 }
 
+// ClientMessageJoinPayload joins a Party by PartyID or InviteCode, or the
+// public queue if both are empty. ClientID and SecretKey are only set to
+// reconnect, along with LastSeq, the Seq of the last message the client
+// received. The embedded PlayerProfile, if set, replaces the client's.
+// Queue names the public queue to join, QueueQuick if empty. Region
+// optionally names where a client joining it plays from.
 type ClientMessageJoinPayload struct {
-	ClientID  ClientID  `json:"clientId"`
-	PartyID   PartyID   `json:"partyId"`
-	SecretKey SecretKey `json:"secret"`
+	ClientID   ClientID   `json:"clientId"`
+	PartyID    PartyID    `json:"partyId"`
+	InviteCode InviteCode `json:"inviteCode,omitempty"`
+	SecretKey  SecretKey  `json:"secret"`
+	Password   string     `json:"password,omitempty"`
+	LastSeq    uint64     `json:"lastSeq,omitempty"`
+	Queue      string     `json:"queue,omitempty"`
+	Region     string     `json:"region,omitempty"`
+	PlayerProfile
+}
+
+// ClientMessageSetProfilePayload replaces the client's PlayerProfile.
+type ClientMessageSetProfilePayload struct {
+	PlayerProfile
+}
+
+// ClientMessageChatPayload is a chat message to the client's Party.
+type ClientMessageChatPayload struct {
+	Text string `json:"text"`
+}
+
+// ClientMessageRematchVotePayload votes for or against a rematch. Votes
+// may be changed until the vote closes.
+type ClientMessageRematchVotePayload struct {
+	Vote bool `json:"vote"`
+}
+
+// ClientMessageUpdatePartyPayload changes the settings of the host's
+// Party. Fields left out are not changed.
+type ClientMessageUpdatePartyPayload struct {
+	PartySettingsUpdate
+}
+
+// ClientMessageListPartiesPayload asks for a page of listed parties. With
+// Subscribe set the client is sent the page again whenever the listings
+// change, until it asks again without Subscribe.
+type ClientMessageListPartiesPayload struct {
+	PartyListingFilter
+	Subscribe bool `json:"subscribe"`
+}
+
+// ClientMessageStartGamePayload carries the host's GameSettings.
+// Fields left out keep their default values.
+//
+// With ReadyCheck set, the game only starts once every connected member is
+// ready or the ready check times out, and only ready members play.
+type ClientMessageStartGamePayload struct {
+	Settings   GameSettings `json:"settings"`
+	ReadyCheck bool         `json:"readyCheck"`
 }
 
-type ClientMessageStartGamePayload struct{}
+type ClientMessageSetReadyPayload struct {
+	Ready bool `json:"ready"`
+}
+
+// ClientMessageCreatePartyPayload may carry the host's PlayerProfile.
+type ClientMessageCreatePartyPayload struct {
+	PlayerProfile
+}
+
+type ClientMessageEndGamePayload struct{}
 
 type ClientMessageLeavePayload struct{}
 
+type ClientMessageCancelQueuePayload struct{}
+
+type ClientMessageListQueuesPayload struct{}
+
+type ClientMessageSnapshotPayload struct{}
+
+// ClientMessageKickMemberPayload names the member the host removes.
+type ClientMessageKickMemberPayload struct {
+	ClientID ClientID `json:"clientId"`
+}
+
+// ClientMessageTransferHostPayload names the member who becomes host.
+type ClientMessageTransferHostPayload struct {
+	ClientID ClientID `json:"clientId"`
+}
+
+// ClientMessageBanMemberPayload names the member the host removes and
+// keeps from rejoining.
+type ClientMessageBanMemberPayload struct {
+	ClientID ClientID `json:"clientId"`
+}
+
+// ClientMessagePlayerActionPayload carries a player's action. ClientTime is
+// the client's local time of the action in Unix milliseconds, if known.
 type ClientMessagePlayerActionPayload struct {
-	Action string `json:"action"`
+	Action     string `json:"action"`
+	ClientTime int64  `json:"clientTime,omitempty"`
+}
+
+// ClientMessageSubmitAnswerPayload answers the puzzle of a pattern round.
+// Choice is the index of the chosen cell, counted row by row.
+type ClientMessageSubmitAnswerPayload struct {
+	Round      int   `json:"round"`
+	Choice     int   `json:"choice"`
+	ClientTime int64 `json:"clientTime,omitempty"`
+}
+
+// ClientMessageClockPongPayload answers a clockPing. ClientTime is the
+// client's local time when the ping arrived, in Unix milliseconds.
+type ClientMessageClockPongPayload struct {
+	ID         uint64 `json:"id"`
+	ClientTime int64  `json:"clientTime"`
 }
 
 // ---------------------------------------------------------------------
 // Server Messages
 // ---------------------------------------------------------------------
 
+// ServerMessage is a message to a client. Seq numbers the messages sent to
+// a client from 1, and continues across reconnects. Clock sync messages
+// and catch ups after a reconnect are not numbered.
 type ServerMessage struct {
 	Type    ServerMessageType `json:"type"`
 	Payload json.RawMessage   `json:"payload"`
+	Seq     uint64            `json:"seq,omitempty"`
 }
 
 type ServerMessageConnectSuccessPayload struct {
 	ClientID  ClientID  `json:"clientId"`
 	SecretKey SecretKey `json:"secret"`
+	PlayerKey PlayerKey `json:"playerKey"`
+	PlayerProfile
 }
 
 type ServerMessageGameStartedPayload struct {
-	CountdownSeconds int   `json:"countdownSeconds"`
-	Timestamp        int64 `json:"timestamp"`
+	CountdownSeconds int          `json:"countdownSeconds"`
+	Timestamp        int64        `json:"timestamp"`
+	Settings         GameSettings `json:"settings"`
+	Players          []PlayerInfo `json:"players"`
 }
 
+// PlayerInfo identifies a player in a Game.
+type PlayerInfo struct {
+	PlayerID ClientID `json:"playerId"`
+	PlayerProfile
+}
+
+// ServerMessageGameEndedPayload announces the end of a Game. Ranking holds
+// each remaining player's final score, best first. WinnerIDs lists every
+// player sharing first place; WinnerID and WinnerName name the first of
+// them in the ranking, which orders tied players by ID.
 type ServerMessageGameEndedPayload struct {
-	WinnerID string `json:"winnerId"`
-	Reason   string `json:"reason"`
+	WinnerID   ClientID         `json:"winnerId"`
+	WinnerName string           `json:"winnerName,omitempty"`
+	WinnerIDs  []ClientID       `json:"winnerIds,omitempty"`
+	Reason     GameEndReason    `json:"reason"`
+	Ranking    []PlayerStanding `json:"ranking"`
+}
+
+type ServerMessageRoundStartedPayload struct {
+	Round     int       `json:"round"`
+	RoundType RoundType `json:"roundType"`
+}
+
+type ServerMessageStimulusPayload struct {
+	Round     int   `json:"round"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+type ServerMessagePuzzlePayload struct {
+	Round     int    `json:"round"`
+	Puzzle    Puzzle `json:"puzzle"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type ServerMessageRoundResultPayload struct {
+	Round     int                 `json:"round"`
+	RoundType RoundType           `json:"roundType"`
+	Results   []RoundPlayerResult `json:"results"`
+}
+
+type ServerMessageStandingsPayload struct {
+	Round     int              `json:"round"`
+	Standings []PlayerStanding `json:"standings"`
+}
+
+// ServerMessageClockPingPayload asks the client to answer with a clockPong
+// holding the same ID. ServerTime is in Unix milliseconds.
+type ServerMessageClockPingPayload struct {
+	ID         uint64 `json:"id"`
+	ServerTime int64  `json:"serverTime"`
+}
+
+// ServerMessageClockSyncPayload reports the server's current estimate of
+// the client's clock offset (client minus server) and round trip time.
+type ServerMessageClockSyncPayload struct {
+	OffsetMs int64 `json:"offsetMs"`
+	RTTMs    int64 `json:"rttMs"`
+}
+
+// ServerMessageReadyCheckPayload asks members to ready up before the
+// Deadline, in Unix milliseconds.
+type ServerMessageReadyCheckPayload struct {
+	Deadline int64 `json:"deadline"`
+}
+
+// ServerMessageAutoStartPayload announces that a public Party starts a
+// game on its own at Deadline, in Unix milliseconds. It is sent again with
+// Cancelled set if too few players are left.
+type ServerMessageAutoStartPayload struct {
+	Deadline  int64 `json:"deadline"`
+	Cancelled bool  `json:"cancelled,omitempty"`
 }
 
+// ServerMessagePartyCreatedPayload returns the ID and invite code of a new
+// private Party. Other clients join it by sending either with a join
+// message.
+type ServerMessagePartyCreatedPayload struct {
+	PartyID    PartyID    `json:"partyId"`
+	InviteCode InviteCode `json:"inviteCode"`
+}
+
+// ServerMessageRematchPayload is the tally of a rematch vote. It is sent
+// when the vote opens, whenever it changes, and once more with Open unset
+// when it closes. The rematch starts if Yes holds Needed players by then.
+// Deadline is in Unix milliseconds.
+type ServerMessageRematchPayload struct {
+	Yes      []ClientID `json:"yes"`
+	No       []ClientID `json:"no"`
+	Needed   int        `json:"needed"`
+	Deadline int64      `json:"deadline"`
+	Open     bool       `json:"open"`
+}
+
+// ServerMessagePartyListPayload is a page of the party browser. Total is
+// the number of parties matching the filter.
+type ServerMessagePartyListPayload struct {
+	Parties []PartyListing `json:"parties"`
+	Total   int            `json:"total"`
+	Offset  int            `json:"offset"`
+}
+
+// ServerMessageReplayPayload holds the messages a reconnecting client
+// missed, oldest first.
+type ServerMessageReplayPayload struct {
+	Messages []ServerMessage `json:"messages"`
+}
+
+// ServerMessageResyncPayload tells a reconnecting client that it missed
+// too many messages to catch up on. It must drop its state and rebuild it
+// from the messages that follow, the first of which has Seq+1.
+type ServerMessageResyncPayload struct {
+	Seq uint64 `json:"seq"`
+}
+
+// ServerMessageSnapshotPayload is the complete state of a member's Party,
+// enough to redraw it from. Settings are those of the current or last game.
+// ReadyCheck, AutoStart and Rematch are set while they are in progress, and
+// Game while the Party plays. Messages with a higher Seq apply on top.
+type ServerMessageSnapshotPayload struct {
+	PartyID       PartyID                         `json:"partyId"`
+	InviteCode    InviteCode                      `json:"inviteCode,omitempty"`
+	Private       bool                            `json:"private"`
+	Queue         string                          `json:"queue,omitempty"`
+	Members       []PartyMemberInfo               `json:"members"`
+	PartySettings PartySettings                   `json:"partySettings"`
+	Settings      GameSettings                    `json:"settings"`
+	ReadyCheck    *ServerMessageReadyCheckPayload `json:"readyCheck,omitempty"`
+	AutoStart     *ServerMessageAutoStartPayload  `json:"autoStart,omitempty"`
+	Rematch       *ServerMessageRematchPayload    `json:"rematch,omitempty"`
+	Chat          []ChatMessage                   `json:"chat"`
+	Game          *GameSnapshot                   `json:"game,omitempty"`
+}
+
+// ServerMessageRatingUpdatePayload tells a player their rating after a
+// rated game, and how much it changed.
+type ServerMessageRatingUpdatePayload struct {
+	Rating int `json:"rating"`
+	Change int `json:"change"`
+}
+
+// Reasons sent with partyLeft.
+const (
+	PartyLeftReasonSelf   = "self-initiated"
+	PartyLeftReasonKicked = "kicked"
+)
+
 type ServerMessagePartyLeftPayload struct {
 	Reason string `json:"reason"`
 }
 
+// ServerMessagePartyJoinedPayload confirms a join. InviteCode is only set
+// for private parties.
 type ServerMessagePartyJoinedPayload struct {
-	PartyID PartyID `json:"partyId"`
+	PartyID    PartyID    `json:"partyId"`
+	InviteCode InviteCode `json:"inviteCode,omitempty"`
 }
 
 type ServerMessageMemberUpdatePayload struct {
 	Members []PartyMemberInfo `json:"members"`
 }
 
-type ServerMessageQueueJoinedPayload struct{}
+// ServerMessageQueueJoinedPayload names the public queue a client joined.
+type ServerMessageQueueJoinedPayload struct {
+	Queue string `json:"queue"`
+}
+
+// ServerMessageQueueStatusPayload tells a queued client where it stands in
+// its Queue. Position counts from 1, longest waiting first.
+// EstimatedWaitMs is how much longer the client can expect to wait, or 0
+// if unknown.
+type ServerMessageQueueStatusPayload struct {
+	Queue           string `json:"queue"`
+	Position        int    `json:"position"`
+	Waiting         int    `json:"waiting"`
+	EstimatedWaitMs int64  `json:"estimatedWaitMs"`
+}
+
+type ServerMessageQueueLeftPayload struct{}
+
+// ServerMessageQueueListPayload describes every public queue.
+type ServerMessageQueueListPayload struct {
+	Queues []QueueInfo `json:"queues"`
+}
 
 type ServerMessageErrorPayload struct {
 	Code        ServerErrorCode   `json:"code"`
@@ -123,10 +448,74 @@ func UnmarshalServerMessage(msg ServerMessage) (any, error) {
 		var p ServerMessageQueueJoinedPayload
 		return p, json.Unmarshal(msg.Payload, &p)
 
+	case ServerMessageQueueStatus:
+		var p ServerMessageQueueStatusPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageQueueLeft:
+		var p ServerMessageQueueLeftPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageQueueList:
+		var p ServerMessageQueueListPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
 	case ServerMessagePartyJoined:
 		var p ServerMessagePartyJoinedPayload
 		return p, json.Unmarshal(msg.Payload, &p)
 
+	case ServerMessagePartyCreated:
+		var p ServerMessagePartyCreatedPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessagePartyLeft:
+		var p ServerMessagePartyLeftPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageReadyCheck:
+		var p ServerMessageReadyCheckPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageAutoStart:
+		var p ServerMessageAutoStartPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageProfileUpdated:
+		var p PlayerProfile
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageChat:
+		var p ChatMessage
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageRematch:
+		var p ServerMessageRematchPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessagePartySettings:
+		var p PartySettings
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessagePartyList:
+		var p ServerMessagePartyListPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageRatingUpdate:
+		var p ServerMessageRatingUpdatePayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageReplay:
+		var p ServerMessageReplayPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageResync:
+		var p ServerMessageResyncPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageSnapshot:
+		var p ServerMessageSnapshotPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
 	case ServerMessageError:
 		var p ServerMessageErrorPayload
 		return p, json.Unmarshal(msg.Payload, &p)
@@ -135,6 +524,42 @@ func UnmarshalServerMessage(msg ServerMessage) (any, error) {
 		var p ServerMessageMemberUpdatePayload
 		return p, json.Unmarshal(msg.Payload, &p)
 
+	case ServerMessageGameStarted:
+		var p ServerMessageGameStartedPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageGameOver:
+		var p ServerMessageGameEndedPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageRoundStarted:
+		var p ServerMessageRoundStartedPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageStimulus:
+		var p ServerMessageStimulusPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessagePuzzle:
+		var p ServerMessagePuzzlePayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageRoundResult:
+		var p ServerMessageRoundResultPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageStandings:
+		var p ServerMessageStandingsPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageClockPing:
+		var p ServerMessageClockPingPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
+	case ServerMessageClockSync:
+		var p ServerMessageClockSyncPayload
+		return p, json.Unmarshal(msg.Payload, &p)
+
 	default:
 		return nil, fmt.Errorf("unknown server message type: %s", msg.Type)
 	}
@@ -153,6 +578,20 @@ func UnmarshalClientMessage(msg ClientMessage) (any, error) {
 		}
 		return payload, nil
 
+	case ClientMessageCreateParty:
+		var payload ClientMessageCreatePartyPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageEndGame:
+		var payload ClientMessageEndGamePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
 	case ClientMessageLeave:
 		var payload ClientMessageLeavePayload
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
@@ -160,8 +599,92 @@ func UnmarshalClientMessage(msg ClientMessage) (any, error) {
 		}
 		return payload, nil
 
+	case ClientMessageCancelQueue:
+		var payload ClientMessageCancelQueuePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageListQueues:
+		var payload ClientMessageListQueuesPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageSnapshot:
+		var payload ClientMessageSnapshotPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageKickMember:
+		var payload ClientMessageKickMemberPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageBanMember:
+		var payload ClientMessageBanMemberPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageSetProfile:
+		var payload ClientMessageSetProfilePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageChat:
+		var payload ClientMessageChatPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageUpdateParty:
+		var payload ClientMessageUpdatePartyPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageListParties:
+		var payload ClientMessageListPartiesPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageRematchVote:
+		var payload ClientMessageRematchVotePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageSetReady:
+		var payload ClientMessageSetReadyPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
+	case ClientMessageTransferHost:
+		var payload ClientMessageTransferHostPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
 	case ClientMessageStartGame:
-		var payload ClientMessageStartGamePayload
+		payload := ClientMessageStartGamePayload{Settings: DefaultGameSettings()}
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return nil, err
 		}
@@ -174,6 +697,23 @@ func UnmarshalClientMessage(msg ClientMessage) (any, error) {
 		}
 		return payload, nil
 
+	case ClientMessageSubmitAnswer:
+		var payload ClientMessageSubmitAnswerPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		if payload.Round < 1 {
+			return nil, fmt.Errorf("answer for round %d", payload.Round)
+		}
+		return payload, nil
+
+	case ClientMessageClockPong:
+		var payload ClientMessageClockPongPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+
 	default:
 		return nil, fmt.Errorf("unknown client message type: %s", msg.Type)
 	}
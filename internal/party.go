@@ -1,12 +1,29 @@
 package internal
 
 import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 )
 
+// Parties hold between minPartySize and maxPartySize members unless the
+// host changes their size, which must stay within minPartySize and
+// maxPartySizeLimit. No game is played by fewer than minPartySize.
+const (
+	maxPartySize      = 6
+	minPartySize      = 2
+	maxPartySizeLimit = 12
+)
+
+// Invite codes are short enough to read aloud and leave out characters
+// that are easily confused, like 0/O and 1/I.
 const (
-	maxPartySize = 6
-	minPartySize = 2
+	inviteCodeLength   = 6
+	inviteCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
 )
 
 // PartyID uniquely identifies a Party instance.
@@ -17,6 +34,25 @@ func NewPartyID() PartyID {
 	return PartyID(uuid.New().String())
 }
 
+// InviteCode is a short, human-friendly code to join a private Party by.
+type InviteCode string
+
+// NewInviteCode creates a random InviteCode. It is not guaranteed to be
+// unique; the PartyManager checks it against live parties.
+func NewInviteCode() InviteCode {
+	b := make([]byte, inviteCodeLength)
+	for i := range b {
+		b[i] = inviteCodeAlphabet[rand.IntN(len(inviteCodeAlphabet))]
+	}
+	return InviteCode(b)
+}
+
+// normalize makes an InviteCode typed by a person comparable: case and
+// surrounding whitespace are ignored.
+func (code InviteCode) normalize() InviteCode {
+	return InviteCode(strings.ToUpper(strings.TrimSpace(string(code))))
+}
+
 // PartyMemberInfo contains relevant information for
 // each party member, like connection status and
 // whether they are a host or not. This information
@@ -26,62 +62,298 @@ type PartyMemberInfo struct {
 	ID          string `json:"id"`
 	IsHost      bool   `json:"isHost"`
 	IsConnected bool   `json:"isConnected"`
+	IsReady     bool   `json:"isReady"`
+	PlayerProfile
 }
 
 // PartyMember carries info related to a client in a Party
 type PartyMember struct {
 	Client      *Client
 	IsConnected bool
+	IsReady     bool
+}
+
+// readyCheck is a game start waiting for the connected members to ready
+// up. Seq tells its timeout apart from those of earlier checks.
+type readyCheck struct {
+	seq      uint64
+	settings GameSettings
+	deadline time.Time
+	timer    *time.Timer
+}
+
+// autoStart is the countdown to a game a public Party starts on its own.
+// Seq tells it apart from earlier countdowns.
+type autoStart struct {
+	seq      uint64
+	deadline time.Time
+	timer    *time.Timer
+}
+
+// rematchVote asks the players of a finished game whether to play again
+// with the same settings. Seq tells its timeout apart from those of
+// earlier votes.
+type rematchVote struct {
+	seq      uint64
+	settings GameSettings
+	votes    map[ClientID]bool
+	deadline time.Time
+	timer    *time.Timer
+}
+
+// rematchTally counts the votes of the connected members. Decided is set
+// once every connected member voted.
+type rematchTally struct {
+	yes     []ClientID
+	no      []ClientID
+	needed  int
+	decided bool
+}
+
+// PartySettings are the options a host sets on their Party.
+type PartySettings struct {
+	Listed      bool `json:"listed"`
+	HasPassword bool `json:"hasPassword"`
+	Locked      bool `json:"locked"`
+	MinSize     int  `json:"minSize"`
+	MaxSize     int  `json:"maxSize"`
+}
+
+// PartySettingsUpdate changes some of the PartySettings. Nil fields are
+// left unchanged, and an empty Password removes the password.
+type PartySettingsUpdate struct {
+	Listed   *bool   `json:"listed,omitempty"`
+	Password *string `json:"password,omitempty"`
+	Locked   *bool   `json:"locked,omitempty"`
+	MinSize  *int    `json:"minSize,omitempty"`
+	MaxSize  *int    `json:"maxSize,omitempty"`
+}
+
+// Validate checks the fields an update sets.
+func (u PartySettingsUpdate) Validate() error {
+	if u.Password != nil {
+		return validatePassword(*u.Password)
+	}
+	return nil
+}
+
+// restricts reports whether the update lists the party, limits who may
+// join it or changes its size, which only private parties allow.
+func (u PartySettingsUpdate) restricts() bool {
+	return (u.Listed != nil && *u.Listed) ||
+		(u.Password != nil && *u.Password != "") ||
+		(u.Locked != nil && *u.Locked) ||
+		u.MinSize != nil || u.MaxSize != nil
 }
 
 // Party represents a pre‑game lobby containing multiple Clients.
 // It is now just a data structure managed by PartyManager.
+//
+// Private parties are created by a host and are never filled from the
+// public queue. They can be joined by ID or by InviteCode, and are shown
+// in the party browser if Listed. The host may lock them or require a
+// password to join, which members reconnecting are not asked for.
 type Party struct {
-	ID      PartyID
-	Members map[ClientID]*PartyMember
-	HostID  ClientID
-	game    *Game
+	ID         PartyID
+	InviteCode InviteCode
+	Members    map[ClientID]*PartyMember
+	HostID     ClientID
+	Private    bool
+	Listed     bool
+	Locked     bool
+	MinSize    int            // fewest members to start a game with
+	MaxSize    int            // most members the party holds
+	password   *partyPassword // nil if none is required
+	joins      rateLimiter    // attempts to join with a password
+	settings   GameSettings   // of the current or last game
+	banned     map[ClientID]bool
+	bannedKeys map[PlayerKey]bool
+	order      []ClientID  // members in join order
+	queue      *MatchQueue // public queue filling the Party, if any
+	readyCheck *readyCheck
+	autoStart  *autoStart
+	rematch    *rematchVote
+	chat       []ChatMessage // the last chatHistorySize messages
+	game       *Game
 }
 
 // NewParty creates a new Party, initializing its member map.
 func NewParty(id PartyID) *Party {
 	return &Party{
-		ID:      id,
-		Members: make(map[ClientID]*PartyMember),
+		ID:         id,
+		Members:    make(map[ClientID]*PartyMember),
+		banned:     make(map[ClientID]bool),
+		bannedKeys: make(map[PlayerKey]bool),
+		settings:   DefaultGameSettings(),
+		MinSize:    minPartySize,
+		MaxSize:    maxPartySize,
 	}
 }
 
 // AddClient adds a client to the party
 func (p *Party) AddClient(c *Client) {
+	if _, exists := p.Members[c.ID]; !exists {
+		p.order = append(p.order, c.ID)
+	}
 	p.Members[c.ID] = &PartyMember{Client: c, IsConnected: true}
 	if len(p.Members) == 1 {
 		p.HostID = c.ID
 	}
 }
 
-// RemoveClient removes a client from the party
+// RemoveClient removes a client from the party. If the host left, the
+// longest-present connected member becomes host.
 func (p *Party) RemoveClient(cid ClientID) {
 	delete(p.Members, cid)
+	for i, id := range p.order {
+		if id == cid {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
 
-	// Check if host left
 	if p.HostID == cid {
-		// Pick the first remaining member as new host
-		for id := range p.Members {
-			p.HostID = id
-			break
+		p.HostID = p.successor()
+	}
+}
+
+// successor returns the member who should take over as host: the earliest
+// joined connected member, or the earliest joined member if none is
+// connected.
+func (p *Party) successor() ClientID {
+	for _, id := range p.order {
+		if p.Members[id].IsConnected {
+			return id
+		}
+	}
+	if len(p.order) > 0 {
+		return p.order[0]
+	}
+	return ""
+}
+
+// TransferHost makes the given member the host. It returns false if the
+// client is not a member.
+func (p *Party) TransferHost(cid ClientID) bool {
+	if _, exists := p.Members[cid]; !exists {
+		return false
+	}
+	p.HostID = cid
+	return true
+}
+
+// NameTaken reports whether another member already uses the display name.
+// Names are compared without regard to case.
+func (p *Party) NameTaken(name string, except ClientID) bool {
+	for cid, m := range p.Members {
+		if cid != except && strings.EqualFold(m.Client.Profile().DisplayName, name) {
+			return true
 		}
 	}
+	return false
+}
+
+// UniqueName returns the display name, numbered if another member already
+// uses it.
+func (p *Party) UniqueName(name string, except ClientID) string {
+	unique := name
+	for n := 2; p.NameTaken(unique, except); n++ {
+		suffix := fmt.Sprintf(" %d", n)
+		runes := []rune(name)
+		if len(runes)+len(suffix) > maxDisplayNameLength {
+			runes = runes[:maxDisplayNameLength-len(suffix)]
+		}
+		unique = string(runes) + suffix
+	}
+	return unique
+}
+
+// Ban keeps a client from joining the party again, including on later
+// connections that present the same PlayerKey.
+func (p *Party) Ban(c *Client) {
+	p.banned[c.ID] = true
+	if c.Player != "" {
+		p.bannedKeys[c.Player] = true
+	}
+}
+
+// IsBanned reports whether the client, or the player behind it, was banned
+// by the host.
+func (p *Party) IsBanned(c *Client) bool {
+	return p.banned[c.ID] || (c.Player != "" && p.bannedKeys[c.Player])
 }
 
-// MarkClientDisconnected marks a client as disconnected
+// MarkClientDisconnected marks a client as disconnected. A disconnected
+// client is no longer ready.
 func (p *Party) MarkClientDisconnected(cid ClientID) bool {
 	if member, exists := p.Members[cid]; exists {
 		member.IsConnected = false
+		member.IsReady = false
 		return true
 	}
 	return false
 }
 
+// SetReady sets a member's ready flag. It returns false if the client is
+// not a member.
+func (p *Party) SetReady(cid ClientID, ready bool) bool {
+	if member, exists := p.Members[cid]; exists {
+		member.IsReady = ready
+		return true
+	}
+	return false
+}
+
+// ResetReady clears every member's ready flag.
+func (p *Party) ResetReady() {
+	for _, m := range p.Members {
+		m.IsReady = false
+	}
+}
+
+// ReadyMembers returns the connected members who are ready, in join order,
+// and whether every connected member is ready.
+func (p *Party) ReadyMembers() ([]*Client, bool) {
+	ready := make([]*Client, 0, len(p.Members))
+	all := true
+	for _, cid := range p.order {
+		m := p.Members[cid]
+		if !m.IsConnected {
+			continue
+		}
+		if m.IsReady {
+			ready = append(ready, m.Client)
+		} else {
+			all = false
+		}
+	}
+	return ready, all
+}
+
+// RematchTally counts the votes of the rematch in progress, which must not
+// be nil. A rematch needs share of the connected members to agree, and no
+// fewer than the game needs.
+func (p *Party) RematchTally(share float64) rematchTally {
+	var t rematchTally
+	connected := 0
+	for _, cid := range p.order {
+		if !p.Members[cid].IsConnected {
+			continue
+		}
+		connected++
+		if vote, voted := p.rematch.votes[cid]; !voted {
+			continue
+		} else if vote {
+			t.yes = append(t.yes, cid)
+		} else {
+			t.no = append(t.no, cid)
+		}
+	}
+	t.needed = max(p.PlayerLimits(p.rematch.settings).Min, int(math.Ceil(share*float64(connected))))
+	t.decided = len(t.yes)+len(t.no) == connected
+	return t
+}
+
 // MarkClientConnected marks a client as connected
 func (p *Party) MarkClientConnected(cid ClientID) bool {
 	if member, exists := p.Members[cid]; exists {
@@ -91,9 +363,94 @@ func (p *Party) MarkClientConnected(cid ClientID) bool {
 	return false
 }
 
+// Settings returns the options the host set on the party.
+func (p *Party) Settings() PartySettings {
+	return PartySettings{
+		Listed:      p.Listed,
+		HasPassword: p.password != nil,
+		Locked:      p.Locked,
+		MinSize:     p.MinSize,
+		MaxSize:     p.MaxSize,
+	}
+}
+
+// ValidateSettings checks an update against the server's limits and the
+// current members, and returns an error describing the first invalid
+// value.
+func (p *Party) ValidateSettings(u PartySettingsUpdate) error {
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	minSize, maxSize := p.MinSize, p.MaxSize
+	if u.MinSize != nil {
+		minSize = *u.MinSize
+	}
+	if u.MaxSize != nil {
+		maxSize = *u.MaxSize
+	}
+	switch {
+	case minSize < minPartySize || minSize > maxPartySizeLimit:
+		return fmt.Errorf("minSize must be between %d and %d", minPartySize, maxPartySizeLimit)
+	case maxSize < minSize || maxSize > maxPartySizeLimit:
+		return fmt.Errorf("maxSize must be between minSize and %d", maxPartySizeLimit)
+	case maxSize < len(p.Members):
+		return fmt.Errorf("maxSize must not be below the %d current members", len(p.Members))
+	}
+	return nil
+}
+
+// UpdateSettings applies a validated PartySettingsUpdate.
+func (p *Party) UpdateSettings(u PartySettingsUpdate) {
+	if u.Listed != nil {
+		p.Listed = *u.Listed
+	}
+	if u.Password != nil {
+		if *u.Password == "" {
+			p.password = nil
+		} else {
+			p.password = newPartyPassword(*u.Password)
+		}
+	}
+	if u.Locked != nil {
+		p.Locked = *u.Locked
+	}
+	if u.MinSize != nil {
+		p.MinSize = *u.MinSize
+	}
+	if u.MaxSize != nil {
+		p.MaxSize = *u.MaxSize
+	}
+}
+
+// PlayerLimits returns the number of players a game with the given
+// settings can start with in this party.
+func (p *Party) PlayerLimits(settings GameSettings) PlayerLimits {
+	mode := gameModePlayers(settings.Mode)
+	return PlayerLimits{
+		Min: max(p.MinSize, mode.Min),
+		Max: min(p.MaxSize, mode.Max),
+	}
+}
+
+// CheckPassword reports whether password lets a new member join.
+func (p *Party) CheckPassword(password string) bool {
+	return p.password == nil || p.password.matches(password)
+}
+
+// ConnectedCount returns the number of connected members.
+func (p *Party) ConnectedCount() int {
+	n := 0
+	for _, m := range p.Members {
+		if m.IsConnected {
+			n++
+		}
+	}
+	return n
+}
+
 // IsFull checks if the Party has reached its maximum member limit.
 func (p *Party) IsFull() bool {
-	return len(p.Members) >= maxPartySize
+	return len(p.Members) >= p.MaxSize
 }
 
 // IsEmpty checks if the party has no members
@@ -101,6 +458,20 @@ func (p *Party) IsEmpty() bool {
 	return len(p.Members) == 0
 }
 
+// AddChat records a chat message in the party's recent history.
+func (p *Party) AddChat(msg ChatMessage) {
+	if len(p.chat) == chatHistorySize {
+		copy(p.chat, p.chat[1:])
+		p.chat = p.chat[:chatHistorySize-1]
+	}
+	p.chat = append(p.chat, msg)
+}
+
+// ChatHistory returns a copy of the recent chat, oldest first.
+func (p *Party) ChatHistory() []ChatMessage {
+	return append([]ChatMessage(nil), p.chat...)
+}
+
 // broadcast sends a ServerMessage to all Clients currently in the Party.
 func (p *Party) broadcast(msgType ServerMessageType, payload any) {
 	for _, m := range p.Members {
@@ -108,14 +479,17 @@ func (p *Party) broadcast(msgType ServerMessageType, payload any) {
 	}
 }
 
-// getMemberInfo returns the PartyMemberInfo for all members
+// getMemberInfo returns the PartyMemberInfo for all members in join order
 func (p *Party) getMemberInfo() []PartyMemberInfo {
 	partyMembers := make([]PartyMemberInfo, 0, len(p.Members))
-	for _, m := range p.Members {
+	for _, cid := range p.order {
+		m := p.Members[cid]
 		partyMembers = append(partyMembers, PartyMemberInfo{
-			ID:          string(m.Client.ID),
-			IsHost:      p.HostID == m.Client.ID,
-			IsConnected: m.IsConnected,
+			ID:            string(m.Client.ID),
+			IsHost:        p.HostID == m.Client.ID,
+			IsConnected:   m.IsConnected,
+			IsReady:       m.IsReady,
+			PlayerProfile: m.Client.Profile(),
 		})
 	}
 	return partyMembers
@@ -1,7 +1,10 @@
 package internal
 
 import (
+	"cmp"
+	"fmt"
 	"log"
+	"math"
 	"time"
 )
 
@@ -10,6 +13,10 @@ const (
 	partyManagerBufferSize = 64
 	cleanupInterval        = 10 * time.Second
 	abandonmentTimeout     = 15 * time.Second
+	readyCheckTimeout      = 15 * time.Second
+	rematchWindow          = 20 * time.Second
+	rematchShare           = 0.5
+	autoStartCountdown     = 5 * time.Second
 )
 
 // PartyManagerCommandType lists all commands sent to the PartyManager.
@@ -17,10 +24,27 @@ type PartyManagerCommandType string
 
 const (
 	PartyManagerCommandAddClient        PartyManagerCommandType = "addClient"
+	PartyManagerCommandCreateParty      PartyManagerCommandType = "createParty"
 	PartyManagerCommandRemoveClient     PartyManagerCommandType = "removeClient"
+	PartyManagerCommandKickClient       PartyManagerCommandType = "kickClient"
+	PartyManagerCommandTransferHost     PartyManagerCommandType = "transferHost"
 	PartyManagerCommandStartGame        PartyManagerCommandType = "startGame"
+	PartyManagerCommandSetReady         PartyManagerCommandType = "setReady"
+	PartyManagerCommandSetProfile       PartyManagerCommandType = "setProfile"
+	PartyManagerCommandChat             PartyManagerCommandType = "chat"
+	PartyManagerCommandReadyTimeout     PartyManagerCommandType = "readyTimeout"
+	PartyManagerCommandAutoStart        PartyManagerCommandType = "autoStart"
+	PartyManagerCommandRematchVote      PartyManagerCommandType = "rematchVote"
+	PartyManagerCommandUpdateParty      PartyManagerCommandType = "updateParty"
+	PartyManagerCommandListParties      PartyManagerCommandType = "listParties"
+	PartyManagerCommandRematchTimeout   PartyManagerCommandType = "rematchTimeout"
+	PartyManagerCommandEndGame          PartyManagerCommandType = "endGame"
 	PartyManagerCommandDisconnectClient PartyManagerCommandType = "clientDisconnected"
 	PartyManagerCommandCleanup          PartyManagerCommandType = "cleanUp"
+	PartyManagerCommandMatchmake        PartyManagerCommandType = "matchmake"
+	PartyManagerCommandCancelQueue      PartyManagerCommandType = "cancelQueue"
+	PartyManagerCommandListQueues       PartyManagerCommandType = "listQueues"
+	PartyManagerCommandSnapshot         PartyManagerCommandType = "snapshot"
 )
 
 // PartyManagerCommand wraps a command and its payload,
@@ -33,10 +57,38 @@ type PartyManagerCommand struct {
 // PartyManagerAddClientPayload is used when a Client joins the queue
 // or attempts to join a specific Party.
 type PartyManagerAddClientPayload struct {
-	Client    *Client   // Current Client Session
-	ClientID  ClientID  // ClientID attempting to reconnect to
-	PartyID   PartyID   // PartyID attempting to join
-	SecretKey SecretKey // SecretKey, for reconnecting
+	Client     *Client       // Current Client Session
+	ClientID   ClientID      // ClientID attempting to reconnect to
+	PartyID    PartyID       // PartyID attempting to join
+	InviteCode InviteCode    // InviteCode of the party attempting to join
+	SecretKey  SecretKey     // SecretKey, for reconnecting
+	LastSeq    uint64        // Seq of the last message received, for reconnecting
+	Password   string        // Password of the party attempting to join, if it has one
+	Profile    PlayerProfile // Profile to join with, if set
+	Queue      string        // Name of the public queue to join, if any
+	Region     string        // Region hint for the public queue, if set
+}
+
+// PartyManagerCreatePartyPayload is sent when a Client wants to
+// create a private Party and host it.
+type PartyManagerCreatePartyPayload struct {
+	Client  *Client
+	Profile PlayerProfile
+}
+
+// PartyManagerSetProfilePayload is sent when a Client changes
+// how it is shown to others.
+type PartyManagerSetProfilePayload struct {
+	Client  *Client
+	Profile PlayerProfile
+}
+
+// PartyManagerChatPayload is sent when a Client writes to its
+// Party's chat.
+type PartyManagerChatPayload struct {
+	Client     *Client
+	Text       string
+	ReceivedAt time.Time
 }
 
 // PartyManagerRemoveClientPayload is used when a Client wants to leave
@@ -45,6 +97,39 @@ type PartyManagerRemoveClientPayload struct {
 	Client *Client
 }
 
+// PartyManagerCancelQueuePayload is sent when a Client gives up waiting
+// in the public queue.
+type PartyManagerCancelQueuePayload struct {
+	Client *Client
+}
+
+// PartyManagerListQueuesPayload is sent when a Client asks which public
+// queues it can join.
+type PartyManagerListQueuesPayload struct {
+	Client *Client
+}
+
+// PartyManagerSnapshotPayload is sent when a Client asks for the complete
+// state of its Party.
+type PartyManagerSnapshotPayload struct {
+	Client *Client
+}
+
+// PartyManagerKickClientPayload is sent when a host removes a member
+// from their Party. Ban also keeps the member from rejoining.
+type PartyManagerKickClientPayload struct {
+	Client   *Client
+	TargetID ClientID
+	Ban      bool
+}
+
+// PartyManagerTransferHostPayload is sent when a host hands the Party
+// over to another member.
+type PartyManagerTransferHostPayload struct {
+	Client   *Client
+	TargetID ClientID
+}
+
 // PartyManagerDisconnectPayload is used when a Client wants to leave
 // a Party or disconnects.
 type PartyManagerDisconnectPayload struct {
@@ -54,6 +139,64 @@ type PartyManagerDisconnectPayload struct {
 // PartyManagerStartGamePayload is sent when a Client wants to
 // start a Game.
 type PartyManagerStartGamePayload struct {
+	Client     *Client
+	Settings   GameSettings
+	ReadyCheck bool
+}
+
+// PartyManagerSetReadyPayload is sent when a Client toggles
+// its ready flag.
+type PartyManagerSetReadyPayload struct {
+	Client *Client
+	Ready  bool
+}
+
+// PartyManagerReadyTimeoutPayload is sent when the ready check
+// of a Party runs out of time.
+type PartyManagerReadyTimeoutPayload struct {
+	PartyID PartyID
+	Seq     uint64
+}
+
+// PartyManagerAutoStartPayload is sent when the countdown of a public
+// Party to its game runs out.
+type PartyManagerAutoStartPayload struct {
+	PartyID PartyID
+	Seq     uint64
+}
+
+// PartyManagerUpdatePartyPayload is sent when a host changes the
+// settings of their Party.
+type PartyManagerUpdatePartyPayload struct {
+	Client *Client
+	Update PartySettingsUpdate
+}
+
+// PartyManagerListPartiesPayload is sent when a Client browses
+// the listed parties.
+type PartyManagerListPartiesPayload struct {
+	Client    *Client
+	Filter    PartyListingFilter
+	Subscribe bool
+}
+
+// PartyManagerRematchVotePayload is sent when a Client votes on
+// a rematch.
+type PartyManagerRematchVotePayload struct {
+	Client *Client
+	Vote   bool
+}
+
+// PartyManagerRematchTimeoutPayload is sent when the rematch vote
+// of a Party closes.
+type PartyManagerRematchTimeoutPayload struct {
+	PartyID PartyID
+	Seq     uint64
+}
+
+// PartyManagerEndGamePayload is sent when a Client wants to
+// end the Game in progress.
+type PartyManagerEndGamePayload struct {
 	Client *Client
 }
 
@@ -69,41 +212,105 @@ type AbandonedClient struct {
 //
 // It runs as its own goroutine, processing commands through its internal
 // `Commands` channel.
+//
+// Players queue for a public game in one of the named Queues, and are
+// placed by its Matchmaker. All queues share the Ratings updated after
+// every public game. Players count as queued until their public Party
+// starts a game, and are told their place in the queue every
+// matchInterval.
 type PartyManager struct {
-	PublicParty *Party
+	Queues      map[string]*MatchQueue // may be added to before clients connect
+	Ratings     *Ratings
 	Parties     map[PartyID]*Party
 	Members     map[ClientID]PartyID
+	InviteCodes map[InviteCode]PartyID
 	Abandoned   map[ClientID]AbandonedClient
 	Games       map[GameID]*Game
 
+	// ChatFilter, if set, moderates every chat message. It is set with
+	// WithChatFilter.
+	ChatFilter ChatFilter
+
 	PublicQueue chan *Client
 	GameEvents  chan GameEvent
 	Commands    chan PartyManagerCommand
 
 	AbandonmentTimeout time.Duration
 	CleanupInterval    time.Duration
+	ReadyCheckTimeout  time.Duration
+
+	// Public parties start their game on their own, AutoStartCountdown
+	// after the Matchmaker finds them filled.
+	AutoStartCountdown time.Duration
+
+	// After a game, members have RematchWindow to vote on a rematch,
+	// which starts if RematchShare of the connected members agree.
+	RematchWindow time.Duration
+	RematchShare  float64
+
+	readyCheckSeq uint64
+	autoStartSeq  uint64
+	rematchSeq    uint64
+
+	browsers        map[ClientID]partyBrowser
+	listings        []PartyListing // last published to browsers
+	listingsChanged bool           // set by handlers that change a listing
+
+	queue map[ClientID]queueEntry
+}
+
+// PartyManagerOption configures a PartyManager before its goroutines start.
+type PartyManagerOption func(*PartyManager)
+
+// WithChatFilter moderates every chat message with f.
+func WithChatFilter(f ChatFilter) PartyManagerOption {
+	return func(pm *PartyManager) {
+		pm.ChatFilter = f
+	}
+}
+
+// WithMaxRTTSpread sets the MaxRTTSpread of the Matchmaker of every queue.
+func WithMaxRTTSpread(d time.Duration) PartyManagerOption {
+	return func(pm *PartyManager) {
+		for _, q := range pm.Queues {
+			q.Matchmaker.MaxRTTSpread = d
+		}
+	}
 }
 
 // NewPartyManager starts and returns a new PartyManager.
-func NewPartyManager() *PartyManager {
-	return NewPartyManagerWithTimeouts(abandonmentTimeout, cleanupInterval)
+func NewPartyManager(opts ...PartyManagerOption) *PartyManager {
+	return NewPartyManagerWithTimeouts(abandonmentTimeout, cleanupInterval, opts...)
 }
 
-func NewPartyManagerWithTimeouts(abandonmentTimeout, cleanupInterval time.Duration) *PartyManager {
+func NewPartyManagerWithTimeouts(abandonmentTimeout, cleanupInterval time.Duration, opts ...PartyManagerOption) *PartyManager {
+	ratings := NewRatings()
 	pm := &PartyManager{
-		PublicParty:        nil,
+		Queues:             defaultQueues(ratings),
+		Ratings:            ratings,
 		Parties:            make(map[PartyID]*Party),
 		Members:            make(map[ClientID]PartyID),
+		InviteCodes:        make(map[InviteCode]PartyID),
 		Abandoned:          make(map[ClientID]AbandonedClient),
 		Games:              make(map[GameID]*Game),
+		browsers:           make(map[ClientID]partyBrowser),
+		queue:              make(map[ClientID]queueEntry),
 		PublicQueue:        make(chan *Client, partyManagerBufferSize),
 		GameEvents:         make(chan GameEvent, partyManagerBufferSize),
 		Commands:           make(chan PartyManagerCommand, partyManagerBufferSize),
 		AbandonmentTimeout: abandonmentTimeout,
 		CleanupInterval:    cleanupInterval,
+		ReadyCheckTimeout:  readyCheckTimeout,
+		AutoStartCountdown: autoStartCountdown,
+		RematchWindow:      rematchWindow,
+		RematchShare:       rematchShare,
+	}
+	for _, opt := range opts {
+		opt(pm)
 	}
 	go pm.Run()
 	go pm.cleanupAbandoned()
+	go pm.matchmake()
 	return pm
 }
 
@@ -119,6 +326,7 @@ func (pm *PartyManager) Run() {
 		case evt := <-pm.GameEvents:
 			pm.handleGameEvent(evt)
 		}
+		pm.publishListings()
 	}
 }
 
@@ -140,10 +348,15 @@ func (pm *PartyManager) handleCommand(cmd PartyManagerCommand) {
 		if abandonedClient, wasAbandoned := pm.Abandoned[clientID]; wasAbandoned {
 			if time.Since(abandonedClient.AbandonedAt) < pm.AbandonmentTimeout && secret == abandonedClient.Client.Secret {
 
-				// Update abandoned client with new connection and send channel
+				// Update abandoned client with new connection and send channel,
+				// and catch it up on what it missed
 				oldClient := abandonedClient.Client
 				oldClient.conn = client.conn
-				oldClient.send = client.send
+				oldClient.resume(client, payload.LastSeq)
+				oldClient.mu.Lock()
+				oldClient.clock = client.clock
+				oldClient.rtt = client.rtt
+				oldClient.mu.Unlock()
 				client = oldClient
 
 				// Check if client was in party
@@ -170,12 +383,15 @@ func (pm *PartyManager) handleCommand(cmd PartyManagerCommand) {
 
 				// Notify client that they re-joined the party
 				client.SendMessage(ServerMessagePartyJoined, ServerMessagePartyJoinedPayload{
-					PartyID: partyID,
+					PartyID:    realPartyID,
+					InviteCode: party.InviteCode,
 				})
 				// Notify other party members
 				party.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
 					Members: party.getMemberInfo(),
 				})
+				// Catch the client up on everything it missed, chat included
+				pm.sendSnapshot(client, party)
 
 				delete(pm.Abandoned, clientID)
 				log.Printf("Client %s reconnected", client.ID)
@@ -190,49 +406,241 @@ func (pm *PartyManager) handleCommand(cmd PartyManagerCommand) {
 		// Check if client is already in a party
 		if _, inParty := pm.Members[client.ID]; inParty {
 			client.SendError(ErrorCodeAlreadyInParty, "Already In Party.", ClientMessageJoin)
+			return
+		}
+		if _, queued := pm.queue[client.ID]; queued {
+			client.SendError(ErrorCodeAlreadyInParty, "Already in the queue.", ClientMessageJoin)
+			return
+		}
+
+		if code := payload.InviteCode.normalize(); code != "" {
+			pid, ok := pm.InviteCodes[code]
+			if !ok {
+				client.SendError(ErrorCodePartyNotFound, "Invite code not found.", ClientMessageJoin)
+				return
+			}
+			partyID = pid
 		}
 
 		if partyID == "" {
 			// client requested to join public queue
-			select {
-			case pm.PublicQueue <- client:
-				client.SendMessage(ServerMessageQueueJoined, map[string]any{})
-			default:
-				client.SendError(ErrorCodeQueueFull, "Queue is full.", ClientMessageJoin)
+			q, ok := pm.Queues[cmp.Or(payload.Queue, QueueQuick)]
+			if !ok {
+				client.SendError(ErrorCodeQueueNotFound, "Queue not found.", ClientMessageJoin)
+				return
+			}
+			region := normalizeRegion(payload.Region)
+			if err := validateRegion(region); err != nil {
+				client.SendError(ErrorCodeInvalidRequest, "Invalid region: "+err.Error()+".", ClientMessageJoin)
+				return
 			}
+			if !pm.applyProfile(client, payload.Profile, nil, ClientMessageJoin) {
+				return
+			}
+			client.region = region
+			pm.enqueue(client, q, time.Now())
 			return
 		}
 
 		// attempt to join specific party
 		if p, ok := pm.Parties[partyID]; ok {
 
-			// Check if party already has an ongoing game
-			if p.game != nil {
+			// Check whether the host lets the client in and there is room
+			if p.IsBanned(client) {
+				client.SendError(ErrorCodeBanned, "Failed to join Party: banned by the host.", ClientMessageJoin)
+				return
+			} else if p.Locked {
+				client.SendError(ErrorCodePartyLocked, "Failed to join Party: locked by the host.", ClientMessageJoin)
+				return
+			} else if p.password != nil && !pm.allowPasswordAttempt(client, p, time.Now()) {
+				client.SendError(ErrorCodeRateLimited, "Failed to join Party: too many password attempts.", ClientMessageJoin)
+				return
+			} else if !p.CheckPassword(payload.Password) {
+				client.SendError(ErrorCodeWrongPassword, "Failed to join Party: wrong password.", ClientMessageJoin)
+				return
+			} else if p.game != nil {
 				client.SendError(ErrorCodeGameInProgress, "Failed to join Party: game in progress.", ClientMessageJoin)
+				return
 			} else if p.IsFull() {
 				client.SendError(ErrorCodePartyFull, "Failed to join Party: already at max capacity.", ClientMessageJoin)
+				return
+			}
+			if !pm.applyProfile(client, payload.Profile, p, ClientMessageJoin) {
+				return
 			}
 
 			p.AddClient(client)
 			pm.Members[client.ID] = partyID
+			pm.listingsChanged = true
 
 			client.SendMessage(ServerMessagePartyJoined, ServerMessagePartyJoinedPayload{
-				PartyID: partyID,
+				PartyID:    partyID,
+				InviteCode: p.InviteCode,
 			})
 			p.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
 				Members: p.getMemberInfo(),
 			})
+			pm.checkRematch(p)
 
 			log.Printf("Client %s joined party %s", client.ID, partyID)
 		} else {
 			client.SendError(ErrorCodePartyNotFound, "Party not found.", ClientMessageJoin)
 		}
 
+	case PartyManagerCommandCreateParty:
+		payload := cmd.Payload.(PartyManagerCreatePartyPayload)
+		client := payload.Client
+
+		if _, inParty := pm.Members[client.ID]; inParty {
+			client.SendError(ErrorCodeAlreadyInParty, "Already In Party.", ClientMessageCreateParty)
+			return
+		}
+
+		if !pm.applyProfile(client, payload.Profile, nil, ClientMessageCreateParty) {
+			return
+		}
+
+		// Private parties are never handed out by handleQueueJoin,
+		// so they can only be joined by their ID
+		p := NewParty(NewPartyID())
+		p.Private = true
+		p.InviteCode = pm.newInviteCode()
+		p.AddClient(client)
+		pm.Parties[p.ID] = p
+		pm.Members[client.ID] = p.ID
+		pm.InviteCodes[p.InviteCode] = p.ID
+
+		client.SendMessage(ServerMessagePartyCreated, ServerMessagePartyCreatedPayload{
+			PartyID:    p.ID,
+			InviteCode: p.InviteCode,
+		})
+		p.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
+			Members: p.getMemberInfo(),
+		})
+
+		log.Printf("Client %s created private party %s", client.ID, p.ID)
+
 	case PartyManagerCommandRemoveClient:
 		payload := cmd.Payload.(PartyManagerRemoveClientPayload)
 		client := payload.Client
 
-		pm.removeClientFromParty(client, ClientMessageLeave)
+		pm.removeClientFromParty(client, ClientMessageLeave, PartyLeftReasonSelf)
+
+	case PartyManagerCommandCancelQueue:
+		payload := cmd.Payload.(PartyManagerCancelQueuePayload)
+		pm.cancelQueue(payload.Client)
+		pm.sendQueueStatus(time.Now())
+
+	case PartyManagerCommandListQueues:
+		payload := cmd.Payload.(PartyManagerListQueuesPayload)
+		payload.Client.SendMessage(ServerMessageQueueList, ServerMessageQueueListPayload{
+			Queues: pm.queueInfos(),
+		})
+
+	case PartyManagerCommandSnapshot:
+		payload := cmd.Payload.(PartyManagerSnapshotPayload)
+		client := payload.Client
+		pid, exists := pm.Members[client.ID]
+		if !exists {
+			client.SendError(ErrorCodeNotInSession, "No session found.", ClientMessageSnapshot)
+			return
+		}
+		p, exists := pm.Parties[pid]
+		if !exists {
+			client.SendError(ErrorCodePartyNotFound, "Party not found.", ClientMessageSnapshot)
+			return
+		}
+		pm.sendSnapshot(client, p)
+
+	case PartyManagerCommandKickClient:
+		payload := cmd.Payload.(PartyManagerKickClientPayload)
+		client := payload.Client
+		cmt := ClientMessageKickMember
+		if payload.Ban {
+			cmt = ClientMessageBanMember
+		}
+
+		pid, exists := pm.Members[client.ID]
+		if !exists {
+			client.SendError(ErrorCodeNotInSession, "No session found.", cmt)
+			return
+		}
+		p, exists := pm.Parties[pid]
+		if !exists {
+			client.SendError(ErrorCodePartyNotFound, "Party not found", cmt)
+			return
+		}
+
+		// Only host can remove members
+		if client.ID != p.HostID {
+			client.SendError(ErrorCodeNotPartyHost, "Not party host.", cmt)
+			return
+		}
+		if payload.TargetID == client.ID {
+			client.SendError(ErrorCodeInvalidRequest, "Cannot remove yourself, leave instead.", cmt)
+			return
+		}
+		member, exists := p.Members[payload.TargetID]
+		if !exists {
+			client.SendError(ErrorCodeMemberNotFound, "Member not found.", cmt)
+			return
+		}
+		target := member.Client
+
+		if payload.Ban {
+			p.Ban(target)
+		}
+
+		// The target is gone for good, so the game must not wait for them
+		delete(pm.Abandoned, target.ID)
+		if p.game != nil {
+			p.game.SendCommand(GameCommand{
+				Type:    GameCommandClientDisconnect,
+				Payload: GameCommandClientDisconnectPayload{ClientID: target.ID},
+			})
+		}
+		target.mu.Lock()
+		target.game = nil
+		target.mu.Unlock()
+
+		pm.removeClientFromParty(target, cmt, PartyLeftReasonKicked)
+		log.Printf("Client %s removed from party %s by host (ban: %t)", target.ID, pid, payload.Ban)
+
+	case PartyManagerCommandTransferHost:
+		payload := cmd.Payload.(PartyManagerTransferHostPayload)
+		client := payload.Client
+		pid, exists := pm.Members[client.ID]
+		if !exists {
+			client.SendError(ErrorCodeNotInSession, "No session found.", ClientMessageTransferHost)
+			return
+		}
+		p, exists := pm.Parties[pid]
+		if !exists {
+			client.SendError(ErrorCodePartyNotFound, "Party not found", ClientMessageTransferHost)
+			return
+		}
+
+		// Only host can hand off the party
+		if client.ID != p.HostID {
+			client.SendError(ErrorCodeNotPartyHost, "Not party host.", ClientMessageTransferHost)
+			return
+		}
+		member, exists := p.Members[payload.TargetID]
+		if !exists {
+			client.SendError(ErrorCodeMemberNotFound, "Member not found.", ClientMessageTransferHost)
+			return
+		}
+		if !member.IsConnected {
+			client.SendError(ErrorCodeInvalidRequest, "Member is disconnected.", ClientMessageTransferHost)
+			return
+		}
+
+		p.TransferHost(payload.TargetID)
+		pm.listingsChanged = true
+		p.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
+			Members: p.getMemberInfo(),
+		})
+		log.Printf("Party %s host transferred to %s", pid, payload.TargetID)
 
 	case PartyManagerCommandStartGame:
 		payload := cmd.Payload.(PartyManagerStartGamePayload)
@@ -256,40 +664,261 @@ func (pm *PartyManager) handleCommand(cmd PartyManagerCommand) {
 			client.SendError(ErrorCodeNotPartyHost, "Not party host.", ClientMessageStartGame)
 			return
 		}
-		// Only start game if there is enough players
-		if len(p.Members) < minPartySize {
-			client.SendError(ErrorCodeNotEnoughMembers, "Party size is too small.", ClientMessageStartGame)
+		if p.game != nil {
+			client.SendError(ErrorCodeGameInProgress, "Game already in progress.", ClientMessageStartGame)
 			return
 		}
-
-		// Create and start game
-		clientsMap := make(map[ClientID]*Client)
-		for cid, member := range p.Members {
-			clientsMap[cid] = member.Client
+		// Public parties play the game of their queue
+		if p.queue != nil {
+			payload.Settings = p.queue.Settings
+		}
+		if err := payload.Settings.Validate(); err != nil {
+			client.SendError(ErrorCodeInvalidSettings, "Invalid settings: "+err.Error()+".", ClientMessageStartGame)
+			return
+		}
+		// Only start game if the party and game mode allow this many players.
+		// With a ready check, members who are not ready sit the game out.
+		limits := p.PlayerLimits(payload.Settings)
+		if len(p.Members) < limits.Min {
+			client.SendError(ErrorCodeNotEnoughMembers, fmt.Sprintf("Party size is too small: need %d players.", limits.Min), ClientMessageStartGame)
+			return
+		}
+		if len(p.Members) > limits.Max && !payload.ReadyCheck {
+			client.SendError(ErrorCodeTooManyMembers, fmt.Sprintf("Party size is too large: at most %d players.", limits.Max), ClientMessageStartGame)
+			return
 		}
 
-		game := NewGame(pm, p, clientsMap)
-		p.game = game
-		pm.Games[game.ID] = game
+		// The host starting a game settles any rematch vote, countdown or
+		// earlier ready check
+		pm.cancelRematch(p)
+		pm.cancelAutoStart(p)
+		pm.cancelReadyCheck(p)
+		if payload.ReadyCheck {
+			pm.startReadyCheck(p, payload.Settings)
+			return
+		}
 
-		// Assign game to each client
+		players := make([]*Client, 0, len(p.Members))
 		for _, member := range p.Members {
-			member.Client.mu.Lock()
-			member.Client.game = game
-			member.Client.mu.Unlock()
+			players = append(players, member.Client)
+		}
+		pm.startGame(p, payload.Settings, players)
+
+	case PartyManagerCommandSetReady:
+		payload := cmd.Payload.(PartyManagerSetReadyPayload)
+		client := payload.Client
+		pid, exists := pm.Members[client.ID]
+		if !exists {
+			client.SendError(ErrorCodeNotInSession, "No session found.", ClientMessageSetReady)
+			return
+		}
+		p, exists := pm.Parties[pid]
+		if !exists {
+			client.SendError(ErrorCodePartyNotFound, "Party not found", ClientMessageSetReady)
+			return
+		}
+
+		p.SetReady(client.ID, payload.Ready)
+		p.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
+			Members: p.getMemberInfo(),
+		})
+		pm.checkReady(p)
+
+	case PartyManagerCommandSetProfile:
+		payload := cmd.Payload.(PartyManagerSetProfilePayload)
+		client := payload.Client
+
+		// Names only need to be unique within the client's party
+		var p *Party
+		if pid, exists := pm.Members[client.ID]; exists {
+			p = pm.Parties[pid]
+		}
+		if payload.Profile.IsZero() {
+			client.SendError(ErrorCodeInvalidProfile, "Invalid profile: displayName must not be empty.", ClientMessageSetProfile)
+			return
+		}
+		if !pm.applyProfile(client, payload.Profile, p, ClientMessageSetProfile) {
+			return
+		}
+
+		client.SendMessage(ServerMessageProfileUpdated, client.Profile())
+		if p != nil {
+			pm.listingsChanged = true
+			p.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
+				Members: p.getMemberInfo(),
+			})
+		}
+
+	case PartyManagerCommandChat:
+		payload := cmd.Payload.(PartyManagerChatPayload)
+		client := payload.Client
+		pid, exists := pm.Members[client.ID]
+		if !exists {
+			client.SendError(ErrorCodeNotInSession, "No session found.", ClientMessageChat)
+			return
+		}
+		p, exists := pm.Parties[pid]
+		if !exists {
+			client.SendError(ErrorCodePartyNotFound, "Party not found", ClientMessageChat)
+			return
+		}
+
+		text, ok := validChatText(payload.Text)
+		if !ok {
+			client.SendError(ErrorCodeInvalidRequest, fmt.Sprintf("Chat messages must be 1 to %d characters.", maxChatLength), ClientMessageChat)
+			return
+		}
+		if !client.chat.allow(payload.ReceivedAt, chatRateLimit, chatRateWindow) {
+			client.SendError(ErrorCodeRateLimited, "Sending chat messages too quickly.", ClientMessageChat)
+			return
+		}
+		if pm.ChatFilter != nil {
+			if text, ok = pm.ChatFilter.Filter(client.ID, text); !ok {
+				client.SendError(ErrorCodeMessageRejected, "Chat message was blocked.", ClientMessageChat)
+				return
+			}
 		}
 
-		game.Start()
-		game.SendCommand(GameCommand{Type: GameCommandStartGame})
+		msg := ChatMessage{
+			SenderID:      client.ID,
+			PlayerProfile: client.Profile(),
+			Text:          text,
+			Time:          payload.ReceivedAt.UnixMilli(),
+		}
+		p.AddChat(msg)
+		p.broadcast(ServerMessageChat, msg)
+
+	case PartyManagerCommandReadyTimeout:
+		payload := cmd.Payload.(PartyManagerReadyTimeoutPayload)
+		p, exists := pm.Parties[payload.PartyID]
+		if !exists || p.readyCheck == nil || p.readyCheck.seq != payload.Seq {
+			return // stale timeout
+		}
+		pm.finishReadyCheck(p)
 
-		log.Printf("Game %s started in party %s", game.ID, pid)
+	case PartyManagerCommandAutoStart:
+		payload := cmd.Payload.(PartyManagerAutoStartPayload)
+		p, exists := pm.Parties[payload.PartyID]
+		if !exists || p.autoStart == nil || p.autoStart.seq != payload.Seq {
+			return // stale countdown
+		}
+		pm.finishAutoStart(p)
+
+	case PartyManagerCommandUpdateParty:
+		payload := cmd.Payload.(PartyManagerUpdatePartyPayload)
+		client := payload.Client
+		pid, exists := pm.Members[client.ID]
+		if !exists {
+			client.SendError(ErrorCodeNotInSession, "No session found.", ClientMessageUpdateParty)
+			return
+		}
+		p, exists := pm.Parties[pid]
+		if !exists {
+			client.SendError(ErrorCodePartyNotFound, "Party not found", ClientMessageUpdateParty)
+			return
+		}
+		if client.ID != p.HostID {
+			client.SendError(ErrorCodeNotPartyHost, "Not party host.", ClientMessageUpdateParty)
+			return
+		}
+		// Public parties are filled by the queue, so anyone may join them
+		if !p.Private && payload.Update.restricts() {
+			client.SendError(ErrorCodeInvalidRequest, "Only private parties can be listed, locked, password protected or resized.", ClientMessageUpdateParty)
+			return
+		}
+		if err := p.ValidateSettings(payload.Update); err != nil {
+			client.SendError(ErrorCodeInvalidRequest, "Invalid party settings: "+err.Error()+".", ClientMessageUpdateParty)
+			return
+		}
+
+		p.UpdateSettings(payload.Update)
+		pm.listingsChanged = true
+		p.broadcast(ServerMessagePartySettings, p.Settings())
+
+	case PartyManagerCommandListParties:
+		payload := cmd.Payload.(PartyManagerListPartiesPayload)
+		client := payload.Client
+
+		listings := pm.partyListings()
+		pm.sendListings(client, listings, payload.Filter)
+		if payload.Subscribe {
+			if len(pm.browsers) == 0 {
+				pm.listings = listings
+			}
+			pm.browsers[client.ID] = partyBrowser{client: client, filter: payload.Filter}
+		} else {
+			delete(pm.browsers, client.ID)
+		}
+
+	case PartyManagerCommandRematchVote:
+		payload := cmd.Payload.(PartyManagerRematchVotePayload)
+		client := payload.Client
+		pid, exists := pm.Members[client.ID]
+		if !exists {
+			client.SendError(ErrorCodeNotInSession, "No session found.", ClientMessageRematchVote)
+			return
+		}
+		p, exists := pm.Parties[pid]
+		if !exists {
+			client.SendError(ErrorCodePartyNotFound, "Party not found", ClientMessageRematchVote)
+			return
+		}
+		if p.rematch == nil {
+			client.SendError(ErrorCodeNoRematch, "No rematch vote in progress.", ClientMessageRematchVote)
+			return
+		}
+
+		p.rematch.votes[client.ID] = payload.Vote
+		pm.checkRematch(p)
+
+	case PartyManagerCommandRematchTimeout:
+		payload := cmd.Payload.(PartyManagerRematchTimeoutPayload)
+		p, exists := pm.Parties[payload.PartyID]
+		if !exists || p.rematch == nil || p.rematch.seq != payload.Seq {
+			return // stale timeout
+		}
+		pm.finishRematch(p)
+
+	case PartyManagerCommandEndGame:
+		payload := cmd.Payload.(PartyManagerEndGamePayload)
+		client := payload.Client
+		pid, exists := pm.Members[client.ID]
+		if !exists {
+			client.SendError(ErrorCodeNotInSession, "No session found.", ClientMessageEndGame)
+			return
+		}
+
+		p, exists := pm.Parties[pid]
+		if !exists {
+			client.SendError(ErrorCodePartyNotFound, "Party not found", ClientMessageEndGame)
+			return
+		}
+
+		// Only host can end the game
+		if client.ID != p.HostID {
+			client.SendError(ErrorCodeNotPartyHost, "Not party host.", ClientMessageEndGame)
+			return
+		}
+		if p.game == nil {
+			client.SendError(ErrorCodeNotInGame, "No game in progress.", ClientMessageEndGame)
+			return
+		}
+
+		p.game.SendCommand(GameCommand{
+			Type:    GameCommandEndGame,
+			Payload: GameCommandEndGamePayload{Reason: GameEndReasonHostEnded},
+		})
 
 	case PartyManagerCommandDisconnectClient:
 		payload := cmd.Payload.(PartyManagerDisconnectPayload)
 		client := payload.Client
+		delete(pm.browsers, client.ID)
 
 		// Tell the party the client disconnected
-		if partyID, exists := pm.Members[client.ID]; exists {
+		if partyID, exists := pm.Members[client.ID]; !exists {
+			// Drop it from the queue if it was never placed in a party
+			delete(pm.queue, client.ID)
+		} else {
 			if party, partyExists := pm.Parties[partyID]; partyExists {
 				party.MarkClientDisconnected(client.ID)
 
@@ -297,6 +926,9 @@ func (pm *PartyManager) handleCommand(cmd PartyManagerCommand) {
 				party.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
 					Members: party.getMemberInfo(),
 				})
+				pm.checkReady(party)
+				pm.checkRematch(party)
+				pm.checkAutoStart(party, time.Now())
 			}
 		}
 
@@ -314,6 +946,7 @@ func (pm *PartyManager) handleCommand(cmd PartyManagerCommand) {
 
 	case PartyManagerCommandCleanup:
 		now := time.Now()
+		pm.Ratings.Expire(now)
 		for cid, abandonedClient := range pm.Abandoned {
 			if now.Sub(abandonedClient.AbandonedAt) > pm.AbandonmentTimeout {
 				delete(pm.Abandoned, cid)
@@ -331,37 +964,115 @@ func (pm *PartyManager) handleCommand(cmd PartyManagerCommand) {
 						}
 					}
 				}
-				pm.removeClientFromParty(&Client{ID: cid}, "")
+				pm.removeClientFromParty(&Client{ID: cid}, "", "")
 				log.Printf("Client %s permanently removed after abandonment", cid)
 			}
 		}
 
+	case PartyManagerCommandMatchmake:
+		now := time.Now()
+		for _, q := range pm.Queues {
+			for _, pair := range q.Matchmaker.merges(now) {
+				pm.mergeParties(pair[0], pair[1])
+			}
+		}
+		for _, p := range pm.Parties {
+			pm.checkAutoStart(p, now)
+		}
+		pm.sendQueueStatus(now)
+
 	default:
 		log.Printf("Unknown party manager command %s", cmd.Type)
 	}
 }
 
-// handleQueueJoin pulls clients off the public queue,
-// creates a new Party if needed, and adds the client to that Party.
+// handleQueueJoin pulls clients off the public queue channel, and adds
+// each to the public Party the Matchmaker of their queue picks for them,
+// creating a new Party if none fits.
 func (pm *PartyManager) handleQueueJoin(c *Client) {
-	if pm.PublicParty == nil || pm.PublicParty.IsFull() || pm.PublicParty.game != nil {
-		pid := NewPartyID()
-		pm.PublicParty = NewParty(pid)
-		pm.Parties[pid] = pm.PublicParty
+	// Skip clients that cancelled or disconnected while in the channel
+	e, queued := pm.queue[c.ID]
+	if !queued || e.client != c {
+		log.Printf("Client %s left the public queue before being placed", c.ID)
+		return
+	}
+	if _, inParty := pm.Members[c.ID]; inParty {
+		return
+	}
+
+	// Players are matched by latency, so wait for the first round trip
+	// time sample before placing the client
+	if _, measured := c.RTT(); !measured && !e.held {
+		e.held = true
+		pm.queue[c.ID] = e
+		go pm.requeueWhenMeasured(c)
+		return
+	}
+
+	now := time.Now()
+	q := e.queue
+	p := q.Matchmaker.lobbyFor(c, now)
+	if p == nil {
+		p = NewParty(NewPartyID())
+		p.queue = q
+		p.settings = q.Settings
+		pm.Parties[p.ID] = p
+		q.Matchmaker.open(p, now)
 	}
-	pm.PublicParty.AddClient(c)
-	pm.Members[c.ID] = pm.PublicParty.ID
+	pm.addPublicMember(p, c)
 
 	c.SendMessage(ServerMessagePartyJoined, ServerMessagePartyJoinedPayload{
-		PartyID: pm.PublicParty.ID,
+		PartyID: p.ID,
 	})
-	pm.PublicParty.broadcast(ServerMessageMemberUpdate,
+	p.broadcast(ServerMessageMemberUpdate,
 		ServerMessageMemberUpdatePayload{
-			Members: pm.PublicParty.getMemberInfo(),
+			Members: p.getMemberInfo(),
 		},
 	)
+	if p.autoStart != nil {
+		// Tell the newcomer when the game starts
+		c.SendMessage(ServerMessageAutoStart, ServerMessageAutoStartPayload{
+			Deadline: p.autoStart.deadline.UnixMilli(),
+		})
+	}
+	pm.checkAutoStart(p, now)
+
+	pm.sendQueueStatus(now)
+
+	log.Printf("Client %s joined public queue %s (party %s)", c.ID, q.Name, p.ID)
+}
+
+// addPublicMember adds a client to a public Party.
+func (pm *PartyManager) addPublicMember(p *Party, c *Client) {
+	// Strangers may share a name, so number it rather than reject it
+	if c.profile.DisplayName != "" {
+		c.profile.DisplayName = p.UniqueName(c.profile.DisplayName, c.ID)
+	}
+	p.AddClient(c)
+	pm.Members[c.ID] = p.ID
+}
 
-	log.Printf("Client %s joined public queue (party %s)", c.ID, pm.PublicParty.ID)
+// mergeParties moves the members of one waiting public Party into another
+// and disbands the emptied Party.
+func (pm *PartyManager) mergeParties(into, from *Party) {
+	pm.cancelRematch(from)
+	pm.cancelRematch(into)
+	for _, cid := range from.order {
+		m := from.Members[cid]
+		pm.addPublicMember(into, m.Client)
+		into.Members[cid].IsConnected = m.IsConnected
+		m.Client.SendMessage(ServerMessagePartyJoined, ServerMessagePartyJoinedPayload{
+			PartyID: into.ID,
+		})
+	}
+	delete(pm.Parties, from.ID)
+	from.queue.Matchmaker.remove(from)
+
+	into.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
+		Members: into.getMemberInfo(),
+	})
+	pm.checkAutoStart(into, time.Now())
+	log.Printf("Public party %s merged into %s", from.ID, into.ID)
 }
 
 // handleGameEvent responds to events emitted by Games.
@@ -380,6 +1091,22 @@ func (pm *PartyManager) handleGameEvent(evt GameEvent) {
 			}
 			// Clear game reference in parent party
 			game.p.game = nil
+			pm.listingsChanged = true
+
+			// Only games between strangers are rated
+			if !game.p.Private && evt.Ranking != nil {
+				pm.rateGame(game, evt.Ranking)
+			}
+
+			// Everyone readies up again for the next game
+			game.p.ResetReady()
+			game.p.broadcast(ServerMessageMemberUpdate, ServerMessageMemberUpdatePayload{
+				Members: game.p.getMemberInfo(),
+			})
+
+			if pm.Parties[game.p.ID] == game.p {
+				pm.openRematch(game.p, game.settings)
+			}
 		}
 		delete(pm.Games, evt.GameID)
 	default:
@@ -397,8 +1124,9 @@ func (pm *PartyManager) SendCommand(cmd PartyManagerCommand) {
 	}
 }
 
-// removeClientFromParty removes a client from a party
-func (pm *PartyManager) removeClientFromParty(c *Client, cmt ClientMessageType) {
+// removeClientFromParty removes a client from a party. The client is sent
+// a partyLeft with the given reason, unless the reason is empty.
+func (pm *PartyManager) removeClientFromParty(c *Client, cmt ClientMessageType, reason string) {
 	pid, exists := pm.Members[c.ID]
 	if !exists {
 		c.SendError(ErrorCodeNotInSession, "Not in any party", cmt)
@@ -413,22 +1141,32 @@ func (pm *PartyManager) removeClientFromParty(c *Client, cmt ClientMessageType)
 	}
 
 	p.RemoveClient(c.ID)
+	pm.listingsChanged = true
 	delete(pm.Members, c.ID)
+	delete(pm.queue, c.ID)
 
 	// Send Client a confirmation
-	if cmt != "" {
+	if reason != "" {
 		c.SendMessage(ServerMessagePartyLeft, ServerMessagePartyLeftPayload{
-			Reason: "self-initiated",
+			Reason: reason,
 		})
 	}
 
 	// If no members left, disband this party
 	if p.IsEmpty() {
 		delete(pm.Parties, pid)
+		pm.cancelReadyCheck(p)
+		if p.autoStart != nil {
+			p.autoStart.timer.Stop()
+		}
+		pm.cancelRematch(p)
+		if p.InviteCode != "" {
+			delete(pm.InviteCodes, p.InviteCode)
+		}
 
-		// If this was the public party, clear the reference
-		if pm.PublicParty != nil && pm.PublicParty.ID == pid {
-			pm.PublicParty = nil
+		// Stop filling it if it was a public party
+		if p.queue != nil {
+			p.queue.Matchmaker.remove(p)
 		}
 
 		// Remove game reference
@@ -445,10 +1183,336 @@ func (pm *PartyManager) removeClientFromParty(c *Client, cmt ClientMessageType)
 			Members: p.getMemberInfo(),
 		},
 	)
+	pm.checkReady(p)
+	pm.checkRematch(p)
+	pm.checkAutoStart(p, time.Now())
 
 	log.Printf("Client left party %s", pid)
 }
 
+// applyProfile validates a profile and sets it on the client. An unset
+// profile keeps the current one, which is still checked against p. The
+// display name must not be taken by another member of p, if p is not nil.
+// It reports the problem to the client and returns false if the profile
+// cannot be used.
+func (pm *PartyManager) applyProfile(c *Client, profile PlayerProfile, p *Party, cmt ClientMessageType) bool {
+	profile = profile.Normalize()
+	if profile.IsZero() {
+		profile = c.profile
+	} else if err := profile.Validate(); err != nil {
+		c.SendError(ErrorCodeInvalidProfile, "Invalid profile: "+err.Error()+".", cmt)
+		return false
+	}
+
+	if p != nil && profile.DisplayName != "" && p.NameTaken(profile.DisplayName, c.ID) {
+		c.SendError(ErrorCodeNameTaken, "Display name already taken in this party.", cmt)
+		return false
+	}
+	c.profile = profile
+	return true
+}
+
+// allowPasswordAttempt reports whether a client may try the password of a
+// Party. Hashing is slow, so attempts are limited both per client and per
+// Party, and counted before the password is checked.
+func (pm *PartyManager) allowPasswordAttempt(c *Client, p *Party, now time.Time) bool {
+	return c.joins.allow(now, passwordAttemptLimit, passwordAttemptWindow) &&
+		p.joins.allow(now, partyPasswordAttemptLimit, passwordAttemptWindow)
+}
+
+// startGame creates and starts a Game for the given members of a Party.
+// The settings are expected to be validated by the caller. A Party plays
+// one game at a time.
+func (pm *PartyManager) startGame(p *Party, settings GameSettings, players []*Client) {
+	if p.game != nil {
+		return
+	}
+	host := p.Members[p.HostID]
+	mode, err := NewGameMode(settings)
+	if err != nil {
+		if host != nil {
+			host.Client.SendError(ErrorCodeInvalidSettings, "Invalid settings: "+err.Error()+".", ClientMessageStartGame)
+		}
+		return
+	}
+
+	clientsMap := make(map[ClientID]*Client)
+	for _, c := range players {
+		clientsMap[c.ID] = c
+	}
+
+	game := NewGame(pm, p, clientsMap, mode, settings)
+	p.game = game
+	p.settings = settings
+	pm.listingsChanged = true
+	pm.Games[game.ID] = game
+	// A public party stops filling once it plays, but keeps its queue
+	// for later games
+	if p.queue != nil {
+		pm.matched(p, time.Now())
+		p.queue.Matchmaker.remove(p)
+	}
+
+	// Assign game to each player
+	for _, c := range players {
+		c.mu.Lock()
+		c.game = game
+		c.mu.Unlock()
+	}
+
+	game.Start()
+	game.SendCommand(GameCommand{Type: GameCommandStartGame})
+
+	log.Printf("Game %s started in party %s", game.ID, p.ID)
+}
+
+// startReadyCheck asks the members of a Party to ready up, replacing any
+// ready check in progress. The game starts once every connected member is
+// ready or the check times out.
+func (pm *PartyManager) startReadyCheck(p *Party, settings GameSettings) {
+	pm.cancelReadyCheck(p)
+	pm.readyCheckSeq++
+	pl := PartyManagerReadyTimeoutPayload{PartyID: p.ID, Seq: pm.readyCheckSeq}
+	p.readyCheck = &readyCheck{
+		seq:      pl.Seq,
+		settings: settings,
+		deadline: time.Now().Add(pm.ReadyCheckTimeout),
+		timer: time.AfterFunc(pm.ReadyCheckTimeout, func() {
+			pm.SendCommand(PartyManagerCommand{Type: PartyManagerCommandReadyTimeout, Payload: pl})
+		}),
+	}
+
+	p.broadcast(ServerMessageReadyCheck, ServerMessageReadyCheckPayload{
+		Deadline: p.readyCheck.deadline.UnixMilli(),
+	})
+	pm.checkReady(p)
+}
+
+// checkReady ends the Party's ready check early once every connected
+// member is ready.
+func (pm *PartyManager) checkReady(p *Party) {
+	if p.readyCheck == nil {
+		return
+	}
+	if _, all := p.ReadyMembers(); all {
+		pm.finishReadyCheck(p)
+	}
+}
+
+// cancelReadyCheck drops the Party's ready check, if any.
+func (pm *PartyManager) cancelReadyCheck(p *Party) {
+	if p.readyCheck == nil {
+		return
+	}
+	p.readyCheck.timer.Stop()
+	p.readyCheck = nil
+}
+
+// finishReadyCheck starts the game with the ready members, or cancels it
+// if too few or too many of them are ready.
+func (pm *PartyManager) finishReadyCheck(p *Party) {
+	rc := p.readyCheck
+	p.readyCheck = nil
+	rc.timer.Stop()
+	if p.game != nil {
+		return
+	}
+
+	players, _ := p.ReadyMembers()
+	limits := p.PlayerLimits(rc.settings)
+	if len(players) < limits.Min {
+		p.broadcast(ServerMessageError, ServerMessageErrorPayload{
+			Code:        ErrorCodeNotEnoughMembers,
+			Message:     "Not enough members are ready.",
+			RequestType: ClientMessageStartGame,
+		})
+		return
+	}
+	if len(players) > limits.Max {
+		p.broadcast(ServerMessageError, ServerMessageErrorPayload{
+			Code:        ErrorCodeTooManyMembers,
+			Message:     fmt.Sprintf("Too many members are ready: at most %d players.", limits.Max),
+			RequestType: ClientMessageStartGame,
+		})
+		return
+	}
+	pm.startGame(p, rc.settings, players)
+}
+
+// checkAutoStart starts the countdown of a public Party the Matchmaker
+// found filled, if enough players are connected, and cancels it when too
+// few are left.
+func (pm *PartyManager) checkAutoStart(p *Party, now time.Time) {
+	if p.queue == nil || p.game != nil || p.readyCheck != nil {
+		return
+	}
+	enough := p.ConnectedCount() >= p.PlayerLimits(p.settings).Min
+	if p.autoStart != nil {
+		if !enough {
+			pm.cancelAutoStart(p)
+		}
+		return
+	}
+	if !enough || !p.queue.Matchmaker.filled(p, now) {
+		return
+	}
+
+	pm.autoStartSeq++
+	pl := PartyManagerAutoStartPayload{PartyID: p.ID, Seq: pm.autoStartSeq}
+	p.autoStart = &autoStart{
+		seq:      pl.Seq,
+		deadline: now.Add(pm.AutoStartCountdown),
+		timer: time.AfterFunc(pm.AutoStartCountdown, func() {
+			pm.SendCommand(PartyManagerCommand{Type: PartyManagerCommandAutoStart, Payload: pl})
+		}),
+	}
+	p.broadcast(ServerMessageAutoStart, ServerMessageAutoStartPayload{
+		Deadline: p.autoStart.deadline.UnixMilli(),
+	})
+	log.Printf("Public party %s starts in %v", p.ID, pm.AutoStartCountdown)
+}
+
+// cancelAutoStart stops the countdown of a public Party, if it has one.
+func (pm *PartyManager) cancelAutoStart(p *Party) {
+	as := p.autoStart
+	if as == nil {
+		return
+	}
+	p.autoStart = nil
+	as.timer.Stop()
+
+	p.broadcast(ServerMessageAutoStart, ServerMessageAutoStartPayload{
+		Deadline:  as.deadline.UnixMilli(),
+		Cancelled: true,
+	})
+}
+
+// finishAutoStart starts the game of a public Party with its connected
+// members once its countdown ran out.
+func (pm *PartyManager) finishAutoStart(p *Party) {
+	p.autoStart = nil
+	if p.game != nil {
+		return
+	}
+
+	players := make([]*Client, 0, len(p.Members))
+	for _, cid := range p.order {
+		if m := p.Members[cid]; m.IsConnected {
+			players = append(players, m.Client)
+		}
+	}
+	if len(players) < p.PlayerLimits(p.settings).Min {
+		return
+	}
+	pm.startGame(p, p.settings, players)
+}
+
+// rateGame updates the ratings of the players of a finished game and
+// tells each their new rating.
+func (pm *PartyManager) rateGame(game *Game, ranking []PlayerStanding) {
+	changes := pm.Ratings.Update(ranking, game.keys, time.Now())
+	for cid, change := range changes {
+		if m, ok := game.p.Members[cid]; ok {
+			m.Client.SendMessage(ServerMessageRatingUpdate, ServerMessageRatingUpdatePayload{
+				Rating: int(math.Round(pm.Ratings.Get(game.keys[cid]))),
+				Change: int(math.Round(change)),
+			})
+		}
+	}
+}
+
+// openRematch lets the members of a Party vote on playing again with the
+// same settings, if enough of them are still connected.
+func (pm *PartyManager) openRematch(p *Party, settings GameSettings) {
+	if p.ConnectedCount() < p.PlayerLimits(settings).Min {
+		return
+	}
+	pm.rematchSeq++
+	pl := PartyManagerRematchTimeoutPayload{PartyID: p.ID, Seq: pm.rematchSeq}
+	p.rematch = &rematchVote{
+		seq:      pl.Seq,
+		settings: settings,
+		votes:    make(map[ClientID]bool),
+		deadline: time.Now().Add(pm.RematchWindow),
+		timer: time.AfterFunc(pm.RematchWindow, func() {
+			pm.SendCommand(PartyManagerCommand{Type: PartyManagerCommandRematchTimeout, Payload: pl})
+		}),
+	}
+	pm.broadcastRematch(p, p.RematchTally(pm.RematchShare), true)
+}
+
+// checkRematch broadcasts the tally of the Party's rematch vote, and
+// closes the vote early once every connected member voted.
+func (pm *PartyManager) checkRematch(p *Party) {
+	if p.rematch == nil {
+		return
+	}
+	if tally := p.RematchTally(pm.RematchShare); tally.decided {
+		pm.finishRematch(p)
+	} else {
+		pm.broadcastRematch(p, tally, true)
+	}
+}
+
+// finishRematch closes the Party's rematch vote and starts the rematch
+// with the members who agreed, if there are enough of them.
+func (pm *PartyManager) finishRematch(p *Party) {
+	tally := p.RematchTally(pm.RematchShare)
+	pm.broadcastRematch(p, tally, false)
+	rv := p.rematch
+	pm.cancelRematch(p)
+
+	if len(tally.yes) < tally.needed {
+		return
+	}
+	if limit := p.PlayerLimits(rv.settings).Max; len(tally.yes) > limit {
+		p.broadcast(ServerMessageError, ServerMessageErrorPayload{
+			Code:        ErrorCodeTooManyMembers,
+			Message:     fmt.Sprintf("Too many members want a rematch: at most %d players.", limit),
+			RequestType: ClientMessageRematchVote,
+		})
+		return
+	}
+	players := make([]*Client, 0, len(tally.yes))
+	for _, cid := range tally.yes {
+		players = append(players, p.Members[cid].Client)
+	}
+	pm.startGame(p, rv.settings, players)
+}
+
+// cancelRematch drops the Party's rematch vote, if any.
+func (pm *PartyManager) cancelRematch(p *Party) {
+	if p.rematch != nil {
+		p.rematch.timer.Stop()
+		p.rematch = nil
+	}
+}
+
+func (pm *PartyManager) broadcastRematch(p *Party, tally rematchTally, open bool) {
+	p.broadcast(ServerMessageRematch, rematchPayload(p, tally, open))
+}
+
+// rematchPayload describes the Party's rematch vote, which must not be nil.
+func rematchPayload(p *Party, tally rematchTally, open bool) ServerMessageRematchPayload {
+	return ServerMessageRematchPayload{
+		Yes:      tally.yes,
+		No:       tally.no,
+		Needed:   tally.needed,
+		Deadline: p.rematch.deadline.UnixMilli(),
+		Open:     open,
+	}
+}
+
+// newInviteCode returns an InviteCode no live party uses.
+func (pm *PartyManager) newInviteCode() InviteCode {
+	for {
+		code := NewInviteCode()
+		if _, taken := pm.InviteCodes[code]; !taken {
+			return code
+		}
+	}
+}
+
 // cleanupAbandoned is a goroutine that sends a
 // PartyManagerCommandCleanup every cleanupInterval
 func (pm *PartyManager) cleanupAbandoned() {
@@ -461,3 +1525,16 @@ func (pm *PartyManager) cleanupAbandoned() {
 		})
 	}
 }
+
+// matchmake is a goroutine that periodically asks the PartyManager to
+// merge waiting public parties.
+func (pm *PartyManager) matchmake() {
+	ticker := time.NewTicker(matchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pm.SendCommand(PartyManagerCommand{
+			Type: PartyManagerCommandMatchmake,
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxPasswordLength = 64
+
+	// Party passwords guard a lobby, not an account, and are checked on
+	// the PartyManager goroutine, so the work factor is kept low.
+	passwordIterations = 10_000
+	passwordSaltLength = 16
+	passwordKeyLength  = 32
+
+	// Within passwordAttemptWindow, a client may try at most
+	// passwordAttemptLimit passwords, and a Party's password may be tried
+	// at most partyPasswordAttemptLimit times.
+	passwordAttemptLimit      = 5
+	partyPasswordAttemptLimit = 20
+	passwordAttemptWindow     = 30 * time.Second
+)
+
+// partyPassword is a salted hash of a Party's join password.
+type partyPassword struct {
+	salt []byte
+	hash []byte
+}
+
+// newPartyPassword hashes a password with a random salt.
+func newPartyPassword(password string) *partyPassword {
+	salt := make([]byte, passwordSaltLength)
+	rand.Read(salt)
+	return &partyPassword{salt: salt, hash: hashPassword(password, salt)}
+}
+
+// matches reports whether password is the one that was hashed.
+func (pp *partyPassword) matches(password string) bool {
+	return subtle.ConstantTimeCompare(hashPassword(password, pp.salt), pp.hash) == 1
+}
+
+func hashPassword(password string, salt []byte) []byte {
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyLength)
+	if err != nil {
+		// Only returned for key lengths FIPS mode forbids
+		panic(err)
+	}
+	return key
+}
+
+// validatePassword checks a password a host wants to set.
+func validatePassword(password string) error {
+	if utf8.RuneCountInString(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d characters", maxPasswordLength)
+	}
+	return nil
+}
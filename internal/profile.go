@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits a PlayerProfile is validated against.
+const (
+	maxDisplayNameLength = 20
+	maxAvatarLength      = 32
+)
+
+// PlayerProfile is how a player is shown to others. It is embedded in the
+// messages that refer to a player, so its fields appear next to the
+// player's ID.
+type PlayerProfile struct {
+	DisplayName string `json:"displayName,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+}
+
+// IsZero reports whether no profile field is set.
+func (pp PlayerProfile) IsZero() bool {
+	return pp.DisplayName == "" && pp.Avatar == ""
+}
+
+// Normalize trims the surrounding whitespace off the display name.
+func (pp PlayerProfile) Normalize() PlayerProfile {
+	pp.DisplayName = strings.TrimSpace(pp.DisplayName)
+	return pp
+}
+
+// Validate checks a normalized profile and returns an error describing the
+// first invalid field.
+//
+// Display names hold letters, digits, spaces and "-_." only. Avatars are an
+// id chosen by the client, made of lowercase letters, digits and dashes.
+func (pp PlayerProfile) Validate() error {
+	if pp.DisplayName == "" {
+		return fmt.Errorf("displayName must not be empty")
+	}
+	if utf8.RuneCountInString(pp.DisplayName) > maxDisplayNameLength {
+		return fmt.Errorf("displayName must be at most %d characters", maxDisplayNameLength)
+	}
+	for _, r := range pp.DisplayName {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" -_.", r) {
+			return fmt.Errorf("displayName must only contain letters, digits, spaces and -_.")
+		}
+	}
+	if strings.Contains(pp.DisplayName, "  ") {
+		return fmt.Errorf("displayName must not contain repeated spaces")
+	}
+
+	if len(pp.Avatar) > maxAvatarLength {
+		return fmt.Errorf("avatar must be at most %d characters", maxAvatarLength)
+	}
+	for _, r := range pp.Avatar {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return fmt.Errorf("avatar must only contain lowercase letters, digits and dashes")
+		}
+	}
+	return nil
+}
+
+// defaultProfile names a player who did not choose a name.
+func defaultProfile(cid ClientID) PlayerProfile {
+	id := strings.ToUpper(string(cid))
+	if len(id) > 4 {
+		id = id[:4]
+	}
+	return PlayerProfile{DisplayName: "Player " + id}
+}
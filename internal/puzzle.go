@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"math/rand/v2"
+)
+
+const (
+	puzzleRows = 4
+	puzzleCols = 4
+)
+
+// PuzzleKind identifies the kind of pattern a player has to recognize.
+type PuzzleKind string
+
+const (
+	// PuzzleKindOddOneOut is a grid of identical symbols except for one.
+	// Players must pick the cell that differs.
+	PuzzleKindOddOneOut PuzzleKind = "oddOneOut"
+
+	// PuzzleKindMatchTarget is a grid of distinct symbols and a target.
+	// Players must pick the cell that matches the target.
+	PuzzleKindMatchTarget PuzzleKind = "matchTarget"
+)
+
+var puzzleKinds = []PuzzleKind{PuzzleKindOddOneOut, PuzzleKindMatchTarget}
+
+// puzzleShapes and puzzleColors combine into the symbols shown to players,
+// e.g. "red-circle".
+var (
+	puzzleShapes = []string{"circle", "square", "triangle", "diamond", "star", "hexagon"}
+	puzzleColors = []string{"red", "blue", "green", "yellow"}
+)
+
+// Puzzle is a server-generated pattern sent to every player in a round.
+// Cells are listed row by row. The correct answer is never sent to clients.
+type Puzzle struct {
+	Kind   PuzzleKind `json:"kind"`
+	Rows   int        `json:"rows"`
+	Cols   int        `json:"cols"`
+	Cells  []string   `json:"cells"`
+	Target string     `json:"target,omitempty"`
+	answer int
+}
+
+// IsCorrect reports whether choice is the index of the correct cell.
+func (p *Puzzle) IsCorrect(choice int) bool {
+	return choice == p.answer
+}
+
+// NewPuzzle generates a random puzzle of a random kind.
+func NewPuzzle() *Puzzle {
+	switch puzzleKinds[rand.IntN(len(puzzleKinds))] {
+	case PuzzleKindMatchTarget:
+		return newMatchTargetPuzzle()
+	default:
+		return newOddOneOutPuzzle()
+	}
+}
+
+// newOddOneOutPuzzle fills the grid with one symbol and replaces a single
+// cell with a symbol sharing either its shape or its color, so the odd
+// cell is similar enough to require attention.
+func newOddOneOutPuzzle() *Puzzle {
+	shape := puzzleShapes[rand.IntN(len(puzzleShapes))]
+	color := puzzleColors[rand.IntN(len(puzzleColors))]
+	base := color + "-" + shape
+
+	odd := base
+	for odd == base {
+		if rand.IntN(2) == 0 {
+			odd = puzzleColors[rand.IntN(len(puzzleColors))] + "-" + shape
+		} else {
+			odd = color + "-" + puzzleShapes[rand.IntN(len(puzzleShapes))]
+		}
+	}
+
+	p := &Puzzle{
+		Kind:   PuzzleKindOddOneOut,
+		Rows:   puzzleRows,
+		Cols:   puzzleCols,
+		Cells:  make([]string, puzzleRows*puzzleCols),
+		answer: rand.IntN(puzzleRows * puzzleCols),
+	}
+	for i := range p.Cells {
+		p.Cells[i] = base
+	}
+	p.Cells[p.answer] = odd
+	return p
+}
+
+// newMatchTargetPuzzle fills the grid with distinct symbols, exactly one
+// of which is the target.
+func newMatchTargetPuzzle() *Puzzle {
+	symbols := make([]string, 0, len(puzzleShapes)*len(puzzleColors))
+	for _, color := range puzzleColors {
+		for _, shape := range puzzleShapes {
+			symbols = append(symbols, color+"-"+shape)
+		}
+	}
+	rand.Shuffle(len(symbols), func(i, j int) {
+		symbols[i], symbols[j] = symbols[j], symbols[i]
+	})
+
+	p := &Puzzle{
+		Kind:   PuzzleKindMatchTarget,
+		Rows:   puzzleRows,
+		Cols:   puzzleCols,
+		Cells:  symbols[:puzzleRows*puzzleCols],
+		answer: rand.IntN(puzzleRows * puzzleCols),
+	}
+	p.Target = p.Cells[p.answer]
+	return p
+}
@@ -0,0 +1,230 @@
+package internal
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"time"
+)
+
+// Names of the built-in public queues. Clients joining the public queue
+// without naming one join QueueQuick.
+const (
+	QueueQuick           = "quick"
+	QueueQuickReaction   = "quick-reaction"
+	QueuePatternMarathon = "pattern-marathon"
+	QueueDuel            = "duel"
+)
+
+const (
+	// Weight of the latest wait in the moving average of queue waits.
+	queueWaitSmoothing = 0.25
+
+	// Rounds of a game in the pattern marathon queue.
+	patternMarathonRounds = 25
+
+	// Longest a queued client waits for its round trip time to be
+	// measured before it is placed without one.
+	queueRTTWait = 2 * time.Second
+)
+
+// MatchQueue is a named public queue. Its players are matched by its own
+// Matchmaker and play games with its Settings.
+//
+// It is owned by the PartyManager goroutine.
+type MatchQueue struct {
+	Name       string
+	Settings   GameSettings
+	Matchmaker *Matchmaker
+
+	wait  time.Duration // moving average of recent waits
+	games int           // games started
+}
+
+// NewMatchQueue creates a MatchQueue. Its public parties fill up to the
+// most players the game mode of the settings allows, and no more than
+// matchTargetSize. The settings are expected to be valid.
+func NewMatchQueue(name string, settings GameSettings, ratings *Ratings) *MatchQueue {
+	mm := NewMatchmaker(ratings)
+	mm.TargetSize = min(mm.TargetSize, gameModePlayers(settings.Mode).Max)
+	return &MatchQueue{Name: name, Settings: settings, Matchmaker: mm}
+}
+
+// defaultQueues returns the built-in public queues.
+func defaultQueues(ratings *Ratings) map[string]*MatchQueue {
+	reaction := DefaultGameSettings()
+	reaction.Mode = GameModeReaction
+	reaction.RoundTypes = []RoundType{RoundTypeReaction}
+
+	marathon := DefaultGameSettings()
+	marathon.Mode = GameModePattern
+	marathon.RoundTypes = []RoundType{RoundTypePattern}
+	marathon.RoundCount = patternMarathonRounds
+
+	duel := DefaultGameSettings()
+	duel.Mode = GameModeDuel
+
+	queues := make(map[string]*MatchQueue)
+	for name, settings := range map[string]GameSettings{
+		QueueQuick:           DefaultGameSettings(),
+		QueueQuickReaction:   reaction,
+		QueuePatternMarathon: marathon,
+		QueueDuel:            duel,
+	} {
+		queues[name] = NewMatchQueue(name, settings, ratings)
+	}
+	return queues
+}
+
+// QueueInfo describes a public queue and how busy it is.
+type QueueInfo struct {
+	Name            string `json:"name"`
+	Mode            string `json:"mode"`
+	Waiting         int    `json:"waiting"`
+	AverageWaitMs   int64  `json:"averageWaitMs"`
+	GamesStarted    int    `json:"gamesStarted"`
+	PlayersPerMatch int    `json:"playersPerMatch"`
+}
+
+// queueEntry is a client waiting in a public queue. It waits from the
+// moment it is queued until its public Party starts a game.
+type queueEntry struct {
+	client   *Client
+	queue    *MatchQueue
+	joinedAt time.Time
+	held     bool // waited for its round trip time already
+}
+
+// enqueue hands a client to a public queue.
+func (pm *PartyManager) enqueue(c *Client, q *MatchQueue, now time.Time) {
+	select {
+	case pm.PublicQueue <- c:
+		pm.queue[c.ID] = queueEntry{client: c, queue: q, joinedAt: now}
+		c.SendMessage(ServerMessageQueueJoined, ServerMessageQueueJoinedPayload{Queue: q.Name})
+	default:
+		c.SendError(ErrorCodeQueueFull, "Queue is full.", ClientMessageJoin)
+	}
+}
+
+// requeueWhenMeasured hands a queued client back to the PartyManager
+// once its round trip time is measured, or after queueRTTWait.
+func (pm *PartyManager) requeueWhenMeasured(c *Client) {
+	timer := time.NewTimer(queueRTTWait)
+	defer timer.Stop()
+	select {
+	case <-c.rttMeasured():
+	case <-timer.C:
+	}
+	pm.PublicQueue <- c
+}
+
+// cancelQueue takes a client out of its public queue, and out of the
+// public Party it is waiting in, if it was placed in one already.
+func (pm *PartyManager) cancelQueue(c *Client) {
+	if _, queued := pm.queue[c.ID]; !queued {
+		c.SendError(ErrorCodeNotInQueue, "Not in the queue.", ClientMessageCancelQueue)
+		return
+	}
+	if _, inParty := pm.Members[c.ID]; inParty {
+		pm.removeClientFromParty(c, ClientMessageCancelQueue, "")
+	}
+	delete(pm.queue, c.ID)
+	c.SendMessage(ServerMessageQueueLeft, ServerMessageQueueLeftPayload{})
+
+	log.Printf("Client %s left the public queue", c.ID)
+}
+
+// matched ends the wait of the queued members of a public Party that
+// started a game, and counts their waits towards its queue's estimate.
+func (pm *PartyManager) matched(p *Party, now time.Time) {
+	q := p.queue
+	q.games++
+	for cid := range p.Members {
+		e, queued := pm.queue[cid]
+		if !queued {
+			continue
+		}
+		delete(pm.queue, cid)
+
+		wait := now.Sub(e.joinedAt)
+		if q.wait == 0 {
+			q.wait = wait
+		} else {
+			q.wait += time.Duration(queueWaitSmoothing * float64(wait-q.wait))
+		}
+	}
+}
+
+// queueConnected reports whether a queued client is still connected.
+// Clients that have not been placed yet are dropped from the queue when
+// they disconnect.
+func (pm *PartyManager) queueConnected(cid ClientID) bool {
+	pid, inParty := pm.Members[cid]
+	if !inParty {
+		return true
+	}
+	if p, ok := pm.Parties[pid]; ok {
+		if m, ok := p.Members[cid]; ok {
+			return m.IsConnected
+		}
+	}
+	return false
+}
+
+// queueEntries returns the clients waiting in each public queue, longest
+// waiting first.
+func (pm *PartyManager) queueEntries() map[*MatchQueue][]queueEntry {
+	entries := make(map[*MatchQueue][]queueEntry, len(pm.Queues))
+	for _, e := range pm.queue {
+		entries[e.queue] = append(entries[e.queue], e)
+	}
+	for _, es := range entries {
+		slices.SortFunc(es, func(a, b queueEntry) int {
+			if c := a.joinedAt.Compare(b.joinedAt); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.client.ID, b.client.ID)
+		})
+	}
+	return entries
+}
+
+// sendQueueStatus tells every connected client in a public queue where it
+// stands.
+func (pm *PartyManager) sendQueueStatus(now time.Time) {
+	for q, entries := range pm.queueEntries() {
+		for i, e := range entries {
+			if !pm.queueConnected(e.client.ID) {
+				continue
+			}
+			var eta time.Duration
+			if q.wait > 0 {
+				eta = max(q.wait-now.Sub(e.joinedAt), 0)
+			}
+			e.client.SendMessage(ServerMessageQueueStatus, ServerMessageQueueStatusPayload{
+				Queue:           q.Name,
+				Position:        i + 1,
+				Waiting:         len(entries),
+				EstimatedWaitMs: eta.Milliseconds(),
+			})
+		}
+	}
+}
+
+// queueInfos describes every public queue, by name.
+func (pm *PartyManager) queueInfos() []QueueInfo {
+	entries := pm.queueEntries()
+	infos := make([]QueueInfo, 0, len(pm.Queues))
+	for _, q := range pm.Queues {
+		infos = append(infos, QueueInfo{
+			Name:            q.Name,
+			Mode:            q.Settings.Mode,
+			Waiting:         len(entries[q]),
+			AverageWaitMs:   q.wait.Milliseconds(),
+			GamesStarted:    q.games,
+			PlayersPerMatch: q.Matchmaker.TargetSize,
+		})
+	}
+	slices.SortFunc(infos, func(a, b QueueInfo) int { return cmp.Compare(a.Name, b.Name) })
+	return infos
+}
@@ -0,0 +1,27 @@
+package internal
+
+import "time"
+
+// rateLimiter enforces a limit on how many events may happen within a
+// sliding window. It is owned by the PartyManager goroutine.
+type rateLimiter struct {
+	sent []time.Time
+}
+
+// allow records an event at the given time and reports whether fewer than
+// limit events were recorded within the window before it. Rejected events
+// do not count.
+func (rl *rateLimiter) allow(at time.Time, limit int, window time.Duration) bool {
+	recent := rl.sent[:0]
+	for _, t := range rl.sent {
+		if at.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	rl.sent = recent
+	if len(rl.sent) >= limit {
+		return false
+	}
+	rl.sent = append(rl.sent, at)
+	return true
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Rating of players who have not finished a rated game yet.
+	defaultRating = 1200
+
+	// Most a player's rating can change after a single game.
+	ratingK = 32
+
+	// Ratings of players who have not finished a rated game for this long
+	// are forgotten.
+	ratingTTL = 30 * 24 * time.Hour
+)
+
+// PlayerKey identifies a player across connections. ClientIDs only last
+// as long as a session, so ratings are kept under the PlayerKey the client
+// presents when connecting.
+type PlayerKey string
+
+// NewPlayerKey creates a new PlayerKey.
+func NewPlayerKey() PlayerKey {
+	return PlayerKey(uuid.New().String())
+}
+
+// parsePlayerKey returns the PlayerKey a client presented, or a new one if
+// it presented none or a malformed one.
+func parsePlayerKey(s string) PlayerKey {
+	if uuid.Validate(s) != nil {
+		return NewPlayerKey()
+	}
+	return PlayerKey(s)
+}
+
+// playerRating is a player's rating and when it last changed.
+type playerRating struct {
+	rating  float64
+	ratedAt time.Time
+}
+
+// Ratings holds the Elo rating of every player who recently finished a
+// rated game. Ratings are only kept in memory, so they are lost when the
+// server restarts.
+//
+// It is owned by the PartyManager goroutine.
+type Ratings struct {
+	ratings map[PlayerKey]playerRating
+}
+
+// NewRatings creates an empty Ratings.
+func NewRatings() *Ratings {
+	return &Ratings{ratings: make(map[PlayerKey]playerRating)}
+}
+
+// Get returns a player's rating.
+func (r *Ratings) Get(key PlayerKey) float64 {
+	if pr, ok := r.ratings[key]; ok {
+		return pr.rating
+	}
+	return defaultRating
+}
+
+// Expire forgets the ratings that have not changed within ratingTTL.
+func (r *Ratings) Expire(now time.Time) {
+	for key, pr := range r.ratings {
+		if now.Sub(pr.ratedAt) > ratingTTL {
+			delete(r.ratings, key)
+		}
+	}
+}
+
+// Update rates a finished game from its final ranking and the players'
+// keys, and returns each player's change.
+//
+// A game of several players counts as a match between every pair of them,
+// won by the better ranked player. The changes are scaled so a game moves
+// a rating by at most ratingK, however many played.
+func (r *Ratings) Update(ranking []PlayerStanding, keys map[ClientID]PlayerKey, now time.Time) map[ClientID]float64 {
+	changes := make(map[ClientID]float64, len(ranking))
+	if len(ranking) < 2 {
+		return changes
+	}
+	k := ratingK / float64(len(ranking)-1)
+	for i, a := range ranking {
+		for _, b := range ranking[i+1:] {
+			expected := 1 / (1 + math.Pow(10, (r.Get(keys[b.PlayerID])-r.Get(keys[a.PlayerID]))/400))
+			actual := 0.5
+			if a.Rank < b.Rank {
+				actual = 1
+			} else if a.Rank > b.Rank {
+				actual = 0
+			}
+			changes[a.PlayerID] += k * (actual - expected)
+			changes[b.PlayerID] -= k * (actual - expected)
+		}
+	}
+	for cid, change := range changes {
+		key := keys[cid]
+		r.ratings[key] = playerRating{rating: r.Get(key) + change, ratedAt: now}
+	}
+	return changes
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// Most recent messages kept for a client to catch up on after it
+// reconnects. A client that missed more is sent a resync instead.
+const replayBufferSize = 256
+
+// replayBuffer numbers the messages sent to a client and keeps the most
+// recent ones, so a client that reconnects can be sent what it missed.
+// The zero value is ready to use. It is guarded by outbox.mu.
+type replayBuffer struct {
+	seq      uint64          // of the last message sent
+	messages []ServerMessage // the last replayBufferSize messages, oldest first
+}
+
+// add numbers a message and keeps it.
+func (rb *replayBuffer) add(msg ServerMessage) ServerMessage {
+	rb.seq++
+	msg.Seq = rb.seq
+	if len(rb.messages) == replayBufferSize {
+		copy(rb.messages, rb.messages[1:])
+		rb.messages = rb.messages[:replayBufferSize-1]
+	}
+	rb.messages = append(rb.messages, msg)
+	return msg
+}
+
+// since returns the messages sent after lastSeq, oldest first. It reports
+// false if some of them are no longer kept, or lastSeq was never sent.
+func (rb *replayBuffer) since(lastSeq uint64) ([]ServerMessage, bool) {
+	if lastSeq > rb.seq {
+		return nil, false
+	}
+	missed := int(rb.seq - lastSeq)
+	if missed > len(rb.messages) {
+		return nil, false
+	}
+	return rb.messages[len(rb.messages)-missed:], true
+}
+
+// sequenced reports whether messages of a type are numbered and replayed.
+// connectSuccess and clock sync messages only make sense on the connection
+// they were sent on.
+func sequenced(msgType ServerMessageType) bool {
+	return msgType != ServerMessageConnectSuccess &&
+		msgType != ServerMessageClockPing && msgType != ServerMessageClockSync
+}
+
+// outbox is where the messages of a session go: the send channel of its
+// current connection, and the replay buffer numbering them. After a
+// reconnect, the Client of the new connection shares the outbox of the
+// session it resumed, so the session keeps a single sequence.
+type outbox struct {
+	mu     sync.Mutex
+	send   chan ServerMessage
+	replay replayBuffer
+}
+
+// outbox returns the outbox of the client's session.
+func (c *Client) outbox() *outbox {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.out == nil {
+		c.out = &outbox{send: c.send}
+	}
+	return c.out
+}
+
+// resume moves a reconnecting client over to the connection of conn, the
+// Client the new connection started with, and catches it up on the
+// messages it missed since lastSeq. If too many were missed it is sent a
+// resync instead, and must rebuild its state from the messages that
+// follow. A lastSeq of 0 skips both.
+func (c *Client) resume(conn *Client, lastSeq uint64) {
+	out := c.outbox()
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.send = conn.send
+	conn.mu.Lock()
+	conn.out = out
+	conn.mu.Unlock()
+	if lastSeq == 0 {
+		return
+	}
+
+	var msg ServerMessage
+	if missed, ok := out.replay.since(lastSeq); !ok {
+		msg.Type = ServerMessageResync
+		msg.Payload, _ = json.Marshal(ServerMessageResyncPayload{Seq: out.replay.seq})
+	} else if len(missed) > 0 {
+		msg.Type = ServerMessageReplay
+		msg.Payload, _ = json.Marshal(ServerMessageReplayPayload{Messages: missed})
+	} else {
+		return
+	}
+	// Messages sent later wait for out.mu, so they follow the catch up
+	select {
+	case out.send <- msg:
+	default:
+		log.Printf("Send buffer full for %s", c.ID)
+	}
+}
@@ -0,0 +1,27 @@
+package internal
+
+import "testing"
+
+// TestReplayBuffer verifies that the replay buffer numbers messages and
+// only replays what it still holds.
+func TestReplayBuffer(t *testing.T) {
+	var rb replayBuffer
+	for range replayBufferSize + 10 {
+		rb.add(ServerMessage{Type: ServerMessageChat})
+	}
+	last := uint64(replayBufferSize + 10)
+
+	missed, ok := rb.since(last - 3)
+	if !ok || len(missed) != 3 || missed[0].Seq != last-2 || missed[2].Seq != last {
+		t.Fatalf("expected the last 3 messages, got %v (ok: %v)", missed, ok)
+	}
+	if missed, ok := rb.since(last); !ok || len(missed) != 0 {
+		t.Fatalf("expected nothing missed, got %v (ok: %v)", missed, ok)
+	}
+	if _, ok := rb.since(5); ok {
+		t.Fatal("expected messages that are no longer kept to need a resync")
+	}
+	if _, ok := rb.since(last + 1); ok {
+		t.Fatal("expected an unknown sequence number to need a resync")
+	}
+}
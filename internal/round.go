@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+// RoundType identifies the kind of challenge played in a round.
+type RoundType string
+
+const (
+	RoundTypeReaction RoundType = "reaction"
+	RoundTypePattern  RoundType = "pattern"
+)
+
+// RoundOutcome describes how a player finished a round.
+type RoundOutcome string
+
+const (
+	RoundOutcomeHit        RoundOutcome = "hit"
+	RoundOutcomeFalseStart RoundOutcome = "falseStart"
+	RoundOutcomeMissed     RoundOutcome = "missed"
+	RoundOutcomeWrong      RoundOutcome = "wrong"
+)
+
+// roundPhase tracks where the Game is within the round cycle.
+type roundPhase string
+
+const (
+	roundPhaseCountdown roundPhase = "countdown" // pre-game countdown
+	roundPhaseWaiting   roundPhase = "waiting"   // round announced, stimulus or puzzle pending
+	roundPhaseLive      roundPhase = "live"      // stimulus or puzzle shown, accepting responses
+	roundPhaseResults   roundPhase = "results"   // round scored, next round pending
+)
+
+// RoundPlayerResult is a single player's result for one round.
+//
+// TimeMs is the time counted against the player: their response time on a
+// hit, the round time limit on a miss, and the time limit plus the
+// false-start penalty on a false start or a wrong answer.
+type RoundPlayerResult struct {
+	PlayerID ClientID     `json:"playerId"`
+	Outcome  RoundOutcome `json:"outcome"`
+	TimeMs   int64        `json:"timeMs"`
+	Rank     int          `json:"rank"`
+	Points   int          `json:"points"`
+	PlayerProfile
+}
+
+// round holds the state of the round currently being played.
+// It is owned by the Game goroutine and must not be shared.
+type round struct {
+	number     int
+	kind       RoundType
+	stimulusAt time.Time
+	puzzle     *Puzzle
+	results    map[ClientID]*RoundPlayerResult
+}
+
+// newRound creates an empty round.
+func newRound(number int, kind RoundType) *round {
+	return &round{
+		number:  number,
+		kind:    kind,
+		results: make(map[ClientID]*RoundPlayerResult),
+	}
+}
+
+// responded reports whether the player already has a result this round.
+func (r *round) responded(cid ClientID) bool {
+	_, ok := r.results[cid]
+	return ok
+}
+
+// recordFalseStart records an early press for the player.
+func (r *round) recordFalseStart(cid ClientID, s GameSettings) {
+	r.results[cid] = &RoundPlayerResult{
+		PlayerID: cid,
+		Outcome:  RoundOutcomeFalseStart,
+		TimeMs:   int64(s.RoundTimeLimitMs + s.FalseStartPenaltyMs),
+	}
+}
+
+// recordAnswer records a puzzle answer made at the given time. Correct
+// answers count as hits; wrong answers carry the false-start penalty.
+func (r *round) recordAnswer(cid ClientID, choice int, at time.Time, s GameSettings) {
+	if !r.puzzle.IsCorrect(choice) {
+		r.results[cid] = &RoundPlayerResult{
+			PlayerID: cid,
+			Outcome:  RoundOutcomeWrong,
+			TimeMs:   int64(s.RoundTimeLimitMs + s.FalseStartPenaltyMs),
+		}
+		return
+	}
+	r.recordHit(cid, at)
+}
+
+// recordHit records a valid press made at the given time.
+func (r *round) recordHit(cid ClientID, at time.Time) {
+	reaction := max(at.Sub(r.stimulusAt).Milliseconds(), 0)
+	r.results[cid] = &RoundPlayerResult{
+		PlayerID: cid,
+		Outcome:  RoundOutcomeHit,
+		TimeMs:   reaction,
+	}
+}
+
+// finish marks every player without a result as missed and returns the
+// results ordered by rank. Players with equal times share a rank.
+func (r *round) finish(players []ClientID, s GameSettings) []RoundPlayerResult {
+	for _, cid := range players {
+		if !r.responded(cid) {
+			r.results[cid] = &RoundPlayerResult{
+				PlayerID: cid,
+				Outcome:  RoundOutcomeMissed,
+				TimeMs:   int64(s.RoundTimeLimitMs),
+			}
+		}
+	}
+
+	results := make([]RoundPlayerResult, 0, len(r.results))
+	for _, res := range r.results {
+		results = append(results, *res)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].TimeMs != results[j].TimeMs {
+			return results[i].TimeMs < results[j].TimeMs
+		}
+		return results[i].PlayerID < results[j].PlayerID
+	})
+	for i := range results {
+		if i > 0 && results[i].TimeMs == results[i-1].TimeMs {
+			results[i].Rank = results[i-1].Rank
+		} else {
+			results[i].Rank = i + 1
+		}
+		r.results[results[i].PlayerID].Rank = results[i].Rank
+	}
+	return results
+}
@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"time"
+)
+
+// Names of the round based game modes.
+const (
+	GameModeClassic  = "classic"
+	GameModeReaction = "reaction"
+	GameModePattern  = "pattern"
+	GameModeDuel     = "duel"
+)
+
+func init() {
+	RegisterGameMode(GameModeClassic, AnyPlayerCount, func(s GameSettings) GameMode {
+		return newRoundsMode(s, s.RoundTypes)
+	})
+	RegisterGameMode(GameModeReaction, AnyPlayerCount, func(s GameSettings) GameMode {
+		return newRoundsMode(s, []RoundType{RoundTypeReaction})
+	})
+	RegisterGameMode(GameModePattern, AnyPlayerCount, func(s GameSettings) GameMode {
+		return newRoundsMode(s, []RoundType{RoundTypePattern})
+	})
+	// Duels are classic games between exactly two players
+	RegisterGameMode(GameModeDuel, PlayerLimits{Min: 2, Max: 2}, func(s GameSettings) GameMode {
+		return newRoundsMode(s, s.RoundTypes)
+	})
+}
+
+// roundsMode plays reaction and pattern rounds in turn.
+//
+// Each round is announced, then after a random delay the stimulus or
+// puzzle is shown and responses are timed until everyone responded or the
+// time limit passes. Results and standings are broadcast after each round.
+type roundsMode struct {
+	settings    GameSettings
+	phase       roundPhase
+	roundNumber int
+	round       *round
+}
+
+// newRoundsMode creates a roundsMode that plays the given round types.
+func newRoundsMode(settings GameSettings, roundTypes []RoundType) *roundsMode {
+	settings.RoundTypes = roundTypes
+	return &roundsMode{settings: settings}
+}
+
+// Start schedules the first round once the countdown has passed.
+func (m *roundsMode) Start(g *Game) {
+	m.phase = roundPhaseCountdown
+	g.schedule(time.Duration(m.settings.CountdownSeconds)*time.Second, string(m.phase))
+}
+
+// Timer moves to the next round phase once the current phase expired.
+func (m *roundsMode) Timer(g *Game, name string) {
+	if roundPhase(name) != m.phase {
+		return
+	}
+	switch m.phase {
+	case roundPhaseCountdown, roundPhaseResults:
+		m.startRound(g)
+	case roundPhaseWaiting:
+		m.showStimulus(g)
+	case roundPhaseLive:
+		m.finishRound(g)
+	}
+}
+
+// PlayerInput routes presses to reaction rounds and answers to pattern
+// rounds. Inputs outside a round, or of the wrong kind, are ignored.
+func (m *roundsMode) PlayerInput(g *Game, in PlayerInput) {
+	if m.round == nil || m.round.responded(in.ClientID) {
+		return
+	}
+	switch in.Kind {
+	case ClientMessagePlayerAction:
+		m.handlePress(g, in)
+	case ClientMessageSubmitAnswer:
+		m.handleAnswer(g, in)
+	}
+}
+
+// PlayerLeft finishes the round early if the player who left was the
+// last one being waited on.
+func (m *roundsMode) PlayerLeft(g *Game, _ ClientID) {
+	if m.phase == roundPhaseLive && m.allResponded(g) {
+		m.finishRound(g)
+	}
+}
+
+// End has nothing to clean up; the Game stops the pending timer.
+func (m *roundsMode) End(*Game, GameEndReason) {}
+
+// Snapshot describes the current phase and round. The end of the waiting
+// phase is left out, so players cannot anticipate the stimulus.
+func (m *roundsMode) Snapshot(g *Game) *RoundSnapshot {
+	s := &RoundSnapshot{Phase: string(m.phase)}
+	if m.phase != roundPhaseWaiting && g.timerAt.After(time.Now()) {
+		s.Deadline = g.timerAt.UnixMilli()
+	}
+	if m.round == nil {
+		return s
+	}
+
+	s.Round, s.RoundType = m.round.number, m.round.kind
+	if !m.round.stimulusAt.IsZero() {
+		s.StimulusAt = m.round.stimulusAt.UnixMilli()
+		s.Puzzle = m.round.puzzle
+	}
+	for _, p := range g.players() {
+		if m.round.responded(p.PlayerID) {
+			s.Responded = append(s.Responded, p.PlayerID)
+		}
+	}
+	return s
+}
+
+// startRound announces a new round and schedules its stimulus after a
+// random delay, so players cannot anticipate it.
+func (m *roundsMode) startRound(g *Game) {
+	m.roundNumber++
+	m.round = newRound(m.roundNumber, m.settings.roundType(m.roundNumber))
+	m.phase = roundPhaseWaiting
+
+	g.broadcast(ServerMessageRoundStarted, ServerMessageRoundStartedPayload{
+		Round:     m.round.number,
+		RoundType: m.round.kind,
+	})
+	g.schedule(m.settings.stimulusDelay(), string(m.phase))
+}
+
+// showStimulus broadcasts the stimulus, or the puzzle for pattern rounds,
+// and starts timing responses.
+func (m *roundsMode) showStimulus(g *Game) {
+	m.phase = roundPhaseLive
+	m.round.stimulusAt = time.Now()
+	g.markStimulus(m.round.number, m.round.kind, m.round.stimulusAt)
+
+	switch m.round.kind {
+	case RoundTypePattern:
+		m.round.puzzle = NewPuzzle()
+		g.broadcast(ServerMessagePuzzle, ServerMessagePuzzlePayload{
+			Round:     m.round.number,
+			Puzzle:    *m.round.puzzle,
+			Timestamp: m.round.stimulusAt.UnixMilli(),
+		})
+	default:
+		g.broadcast(ServerMessageStimulus, ServerMessageStimulusPayload{
+			Round:     m.round.number,
+			Timestamp: m.round.stimulusAt.UnixMilli(),
+		})
+	}
+	g.schedule(time.Duration(m.settings.RoundTimeLimitMs)*time.Millisecond, string(m.phase))
+}
+
+// finishRound scores the current round, broadcasts the results and
+// standings, and either schedules the next round or ends the Game.
+func (m *roundsMode) finishRound(g *Game) {
+	m.phase = roundPhaseResults
+	players := g.playerIDs()
+	results := m.round.finish(players, m.settings)
+	g.scores.award(results)
+
+	g.broadcast(ServerMessageRoundResult, ServerMessageRoundResultPayload{
+		Round:     m.round.number,
+		RoundType: m.round.kind,
+		Results:   results,
+	})
+	g.broadcast(ServerMessageStandings, ServerMessageStandingsPayload{
+		Round:     m.round.number,
+		Standings: g.scores.standings(players),
+	})
+
+	if m.isComplete(g) {
+		g.finish(GameEndReasonCompleted)
+		return
+	}
+	g.schedule(time.Duration(m.settings.IntermissionMs)*time.Millisecond, string(m.phase))
+}
+
+// isComplete reports whether the round count or target score was reached.
+func (m *roundsMode) isComplete(g *Game) bool {
+	if m.settings.RoundCount > 0 && m.roundNumber >= m.settings.RoundCount {
+		return true
+	}
+	return m.settings.TargetScore > 0 && g.scores.leader() >= m.settings.TargetScore
+}
+
+// handlePress timestamps a player's press against the current reaction
+// round. Only the first press of each round counts. Presses before the
+// stimulus are false starts.
+func (m *roundsMode) handlePress(g *Game, in PlayerInput) {
+	if m.round.kind != RoundTypeReaction {
+		return
+	}
+
+	switch m.phase {
+	case roundPhaseWaiting:
+		m.round.recordFalseStart(in.ClientID, m.settings)
+	case roundPhaseLive:
+		m.round.recordHit(in.ClientID, in.At)
+	default:
+		return
+	}
+
+	if m.phase == roundPhaseLive && m.allResponded(g) {
+		m.finishRound(g)
+	}
+}
+
+// handleAnswer scores a puzzle answer against the current pattern round.
+// Only the first answer of each round counts, and only once the puzzle
+// has been shown.
+func (m *roundsMode) handleAnswer(g *Game, in PlayerInput) {
+	if m.round.kind != RoundTypePattern || m.phase != roundPhaseLive || in.Round != m.round.number {
+		return
+	}
+	m.round.recordAnswer(in.ClientID, in.Choice, in.At, m.settings)
+
+	if m.allResponded(g) {
+		m.finishRound(g)
+	}
+}
+
+// allResponded reports whether every remaining player has a result for
+// the current round. Disqualified players are not waited for, since their
+// inputs are dropped.
+func (m *roundsMode) allResponded(g *Game) bool {
+	for _, cid := range g.playerIDs() {
+		if !g.scores.disqualified[cid] && !m.round.responded(cid) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"sort"
+)
+
+// Points awarded or deducted per round.
+const (
+	falseStartPoints = -1
+)
+
+// PlayerStanding is a player's overall position in a Game.
+// Flags lists the anti-cheat checks the player failed, and Left marks
+// players who left before the end. Both are only set in the final ranking.
+type PlayerStanding struct {
+	PlayerID     ClientID      `json:"playerId"`
+	Score        int           `json:"score"`
+	Rank         int           `json:"rank"`
+	Flags        []CheatReason `json:"flags,omitempty"`
+	Disqualified bool          `json:"disqualified,omitempty"`
+	Left         bool          `json:"left,omitempty"`
+	PlayerProfile
+}
+
+// scoreboard accumulates points across the rounds of a Game.
+//
+// Players with equal scores are separated by their total time across
+// rounds, lowest first. Disqualified players rank below everyone else.
+// Results and standings are labelled with the players' profiles as they
+// were when the Game started. It is owned by the Game goroutine.
+type scoreboard struct {
+	rounds       int
+	scores       map[ClientID]int
+	times        map[ClientID]int64
+	disqualified map[ClientID]bool
+	profiles     map[ClientID]PlayerProfile
+}
+
+// newScoreboard creates an empty scoreboard for players with the given
+// profiles.
+func newScoreboard(profiles map[ClientID]PlayerProfile) *scoreboard {
+	return &scoreboard{
+		scores:       make(map[ClientID]int),
+		times:        make(map[ClientID]int64),
+		disqualified: make(map[ClientID]bool),
+		profiles:     profiles,
+	}
+}
+
+// disqualify ranks the player last for the rest of the Game.
+func (s *scoreboard) disqualify(cid ClientID) {
+	s.disqualified[cid] = true
+}
+
+// award assigns points to ranked round results and adds them to the
+// scoreboard. Hits earn more points the better they rank; misses earn
+// nothing; false starts and wrong answers lose points.
+func (s *scoreboard) award(results []RoundPlayerResult) {
+	s.rounds++
+	for i := range results {
+		res := &results[i]
+		res.PlayerProfile = s.profiles[res.PlayerID]
+		switch res.Outcome {
+		case RoundOutcomeHit:
+			res.Points = len(results) - res.Rank + 1
+		case RoundOutcomeFalseStart, RoundOutcomeWrong:
+			res.Points = falseStartPoints
+		}
+		s.scores[res.PlayerID] += res.Points
+		s.times[res.PlayerID] += res.TimeMs
+	}
+}
+
+// leader returns the highest score of a player still in contention.
+func (s *scoreboard) leader() int {
+	best := 0
+	for cid, score := range s.scores {
+		if !s.disqualified[cid] {
+			best = max(best, score)
+		}
+	}
+	return best
+}
+
+// standings ranks the given players, best first.
+func (s *scoreboard) standings(players []ClientID) []PlayerStanding {
+	sorted := make([]ClientID, len(players))
+	copy(sorted, players)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if s.disqualified[a] != s.disqualified[b] {
+			return s.disqualified[b]
+		}
+		if s.scores[a] != s.scores[b] {
+			return s.scores[a] > s.scores[b]
+		}
+		if s.times[a] != s.times[b] {
+			return s.times[a] < s.times[b]
+		}
+		return a < b
+	})
+
+	standings := make([]PlayerStanding, len(sorted))
+	for i, cid := range sorted {
+		standings[i] = PlayerStanding{
+			PlayerID:      cid,
+			Score:         s.scores[cid],
+			Rank:          i + 1,
+			Disqualified:  s.disqualified[cid],
+			PlayerProfile: s.profiles[cid],
+		}
+		if i > 0 {
+			prev := sorted[i-1]
+			if s.disqualified[prev] == s.disqualified[cid] &&
+				s.scores[prev] == s.scores[cid] && s.times[prev] == s.times[cid] {
+				standings[i].Rank = standings[i-1].Rank
+			}
+		}
+	}
+	return standings
+}
+
+// winners returns the players sharing first place in a ranking, unless
+// they were disqualified or left.
+func winners(ranking []PlayerStanding) []PlayerStanding {
+	var top []PlayerStanding
+	for _, s := range ranking {
+		if s.Rank != 1 || s.Disqualified || s.Left {
+			break
+		}
+		top = append(top, s)
+	}
+	return top
+}
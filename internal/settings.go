@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Default round timing used when a Game is created.
+const (
+	defaultCountdownSeconds    = 3
+	defaultMinStimulusDelayMs  = 1500
+	defaultMaxStimulusDelayMs  = 4000
+	defaultFalseStartPenaltyMs = 1000
+	defaultRoundTimeLimitMs    = 3000
+	defaultIntermissionMs      = 2000
+	defaultRoundCount          = 10
+	defaultMinReactionMs       = 100
+)
+
+// Limits a host's GameSettings are validated against.
+const (
+	maxRoundCount          = 50
+	maxTargetScore         = 500
+	maxRoundTypes          = 20
+	maxCountdownSeconds    = 10
+	maxStimulusDelayMs     = 10000
+	maxFalseStartPenaltyMs = 10000
+	minRoundTimeLimitMs    = 200
+	maxRoundTimeLimitMs    = 30000
+	maxIntermissionMs      = 10000
+	maxMinReactionMs       = 1000
+)
+
+// GameSettings selects the GameMode by name and controls the timing of
+// its rounds. All durations are expressed in milliseconds.
+//
+// RoundTypes are played in order and repeat once exhausted. The Game ends
+// after RoundCount rounds or once a player reaches TargetScore, whichever
+// comes first; a zero value disables that limit.
+//
+// Responses faster than MinReactionMs are rejected as cheats, and a zero
+// value disables the check. DisqualifyCheaters also removes offending
+// players from contention for the rest of the Game.
+type GameSettings struct {
+	Mode                string      `json:"mode"`
+	RoundCount          int         `json:"roundCount"`
+	TargetScore         int         `json:"targetScore"`
+	RoundTypes          []RoundType `json:"roundTypes"`
+	CountdownSeconds    int         `json:"countdownSeconds"`
+	MinStimulusDelayMs  int         `json:"minStimulusDelayMs"`
+	MaxStimulusDelayMs  int         `json:"maxStimulusDelayMs"`
+	FalseStartPenaltyMs int         `json:"falseStartPenaltyMs"`
+	RoundTimeLimitMs    int         `json:"roundTimeLimitMs"`
+	IntermissionMs      int         `json:"intermissionMs"`
+	MinReactionMs       int         `json:"minReactionMs"`
+	DisqualifyCheaters  bool        `json:"disqualifyCheaters"`
+}
+
+// DefaultGameSettings returns the settings used when none are provided.
+func DefaultGameSettings() GameSettings {
+	return GameSettings{
+		Mode:                GameModeClassic,
+		RoundCount:          defaultRoundCount,
+		RoundTypes:          []RoundType{RoundTypeReaction, RoundTypePattern},
+		CountdownSeconds:    defaultCountdownSeconds,
+		MinStimulusDelayMs:  defaultMinStimulusDelayMs,
+		MaxStimulusDelayMs:  defaultMaxStimulusDelayMs,
+		FalseStartPenaltyMs: defaultFalseStartPenaltyMs,
+		RoundTimeLimitMs:    defaultRoundTimeLimitMs,
+		IntermissionMs:      defaultIntermissionMs,
+		MinReactionMs:       defaultMinReactionMs,
+	}
+}
+
+// Validate checks the settings against the server's limits and returns
+// an error describing the first invalid value.
+func (s GameSettings) Validate() error {
+	switch {
+	case gameModes[s.Mode].factory == nil:
+		return fmt.Errorf("unknown game mode %q", s.Mode)
+	case s.RoundCount < 0 || s.RoundCount > maxRoundCount:
+		return fmt.Errorf("roundCount must be between 0 and %d", maxRoundCount)
+	case s.TargetScore < 0 || s.TargetScore > maxTargetScore:
+		return fmt.Errorf("targetScore must be between 0 and %d", maxTargetScore)
+	case s.RoundCount == 0 && s.TargetScore == 0:
+		return fmt.Errorf("roundCount or targetScore must be set")
+	case len(s.RoundTypes) == 0 || len(s.RoundTypes) > maxRoundTypes:
+		return fmt.Errorf("roundTypes must list between 1 and %d round types", maxRoundTypes)
+	case s.CountdownSeconds < 0 || s.CountdownSeconds > maxCountdownSeconds:
+		return fmt.Errorf("countdownSeconds must be between 0 and %d", maxCountdownSeconds)
+	case s.MinStimulusDelayMs < 0 || s.MinStimulusDelayMs > maxStimulusDelayMs:
+		return fmt.Errorf("minStimulusDelayMs must be between 0 and %d", maxStimulusDelayMs)
+	case s.MaxStimulusDelayMs < s.MinStimulusDelayMs || s.MaxStimulusDelayMs > maxStimulusDelayMs:
+		return fmt.Errorf("maxStimulusDelayMs must be between minStimulusDelayMs and %d", maxStimulusDelayMs)
+	case s.FalseStartPenaltyMs < 0 || s.FalseStartPenaltyMs > maxFalseStartPenaltyMs:
+		return fmt.Errorf("falseStartPenaltyMs must be between 0 and %d", maxFalseStartPenaltyMs)
+	case s.RoundTimeLimitMs < minRoundTimeLimitMs || s.RoundTimeLimitMs > maxRoundTimeLimitMs:
+		return fmt.Errorf("roundTimeLimitMs must be between %d and %d", minRoundTimeLimitMs, maxRoundTimeLimitMs)
+	case s.IntermissionMs < 0 || s.IntermissionMs > maxIntermissionMs:
+		return fmt.Errorf("intermissionMs must be between 0 and %d", maxIntermissionMs)
+	case s.MinReactionMs < 0 || s.MinReactionMs > maxMinReactionMs:
+		return fmt.Errorf("minReactionMs must be between 0 and %d", maxMinReactionMs)
+	}
+
+	for _, rt := range s.RoundTypes {
+		switch rt {
+		case RoundTypeReaction, RoundTypePattern:
+		default:
+			return fmt.Errorf("unknown round type %q", rt)
+		}
+	}
+	return nil
+}
+
+// stimulusDelay picks a random delay between the configured bounds.
+func (s GameSettings) stimulusDelay() time.Duration {
+	delay := s.MinStimulusDelayMs
+	if spread := s.MaxStimulusDelayMs - s.MinStimulusDelayMs; spread > 0 {
+		delay += rand.IntN(spread + 1)
+	}
+	return time.Duration(delay) * time.Millisecond
+}
+
+// roundType returns the type of the given round number, starting at 1.
+func (s GameSettings) roundType(number int) RoundType {
+	if len(s.RoundTypes) == 0 {
+		return RoundTypeReaction
+	}
+	return s.RoundTypes[(number-1)%len(s.RoundTypes)]
+}
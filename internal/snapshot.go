@@ -0,0 +1,50 @@
+package internal
+
+// GameSnapshot is the state of a Game at Timestamp, in Unix milliseconds:
+// who plays, the standings so far and, if the GameMode describes it, the
+// round in progress.
+type GameSnapshot struct {
+	GameID    GameID           `json:"gameId"`
+	Settings  GameSettings     `json:"settings"`
+	Players   []PlayerInfo     `json:"players"`
+	Standings []PlayerStanding `json:"standings"`
+	Round     *RoundSnapshot   `json:"round,omitempty"`
+	Timestamp int64            `json:"timestamp"`
+}
+
+// sendSnapshot sends a member the complete state of their Party. While the
+// Party plays, the message is sent from the Game goroutine, so that it
+// neither misses nor repeats what the Game broadcasts around it. The
+// PartyManager does not wait for it.
+func (pm *PartyManager) sendSnapshot(c *Client, p *Party) {
+	snap := ServerMessageSnapshotPayload{
+		PartyID:       p.ID,
+		InviteCode:    p.InviteCode,
+		Private:       p.Private,
+		Members:       p.getMemberInfo(),
+		PartySettings: p.Settings(),
+		Settings:      p.settings,
+		Chat:          p.ChatHistory(),
+	}
+	if p.queue != nil {
+		snap.Queue = p.queue.Name
+	}
+	if p.readyCheck != nil {
+		snap.ReadyCheck = &ServerMessageReadyCheckPayload{Deadline: p.readyCheck.deadline.UnixMilli()}
+	}
+	if p.autoStart != nil {
+		snap.AutoStart = &ServerMessageAutoStartPayload{Deadline: p.autoStart.deadline.UnixMilli()}
+	}
+	if p.rematch != nil {
+		rp := rematchPayload(p, p.RematchTally(pm.RematchShare), true)
+		snap.Rematch = &rp
+	}
+
+	if p.game != nil && p.game.Snapshot(func(gs *GameSnapshot) {
+		snap.Game = gs
+		c.SendMessage(ServerMessageSnapshot, snap)
+	}) {
+		return
+	}
+	c.SendMessage(ServerMessageSnapshot, snap)
+}